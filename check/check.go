@@ -0,0 +1,202 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package check
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	"github.com/hellofresh/spinnaker-resource/trigger"
+)
+
+// Run executes the check step, reading the request from stdin and writing the
+// detected versions to stdout.
+func Run(stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	var request concourse.CheckRequest
+	if err := concourse.ReadRequest(stdin, &request); err != nil {
+		return err
+	}
+
+	spinClient, err := spinnaker.NewClient(request.Source)
+	if err != nil {
+		return concourse.Fail("check step failed", err)
+	}
+
+	versions, err := Versions(stderr, spinClient, request.Version)
+	if err != nil {
+		return concourse.Fail("check step failed", err)
+	}
+	return concourse.WriteResponse(stdout, versions)
+}
+
+// Versions returns the versions check emits after the previous version, or only the latest one
+// when there is no previous version.
+func Versions(stderr io.Writer, spinClient spinnaker.SpinClient, version concourse.Version) (concourse.CheckResponse, error) {
+	source := spinClient.Source()
+
+	Data, err := spinClient.GetPipelineExecutions(version.Ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if version.Ref != "" && len(Data) >= spinnaker.MaxExecutionWindow && !containsExecution(Data, version.Ref) {
+		concourse.Sayf(stderr, "Previous version %s is not among the %d most recent executions, executions older than those are not emitted\n", version.Ref, len(Data))
+	}
+
+	cursor, hasCursor := cursorPosition(version, Data)
+
+	pipelineExecutions := filterPipeline(spinClient.PipelineConfig(), Data)
+
+	pipelineExecutions = filterStatus(source.Statuses, pipelineExecutions)
+
+	pipelineExecutions = filterTrigger(source, pipelineExecutions)
+
+	pipelineExecutions = spinClient.GetPipelineExecutionsWithRunningStage(pipelineExecutions)
+
+	pipelineExecutions = filterStarted(pipelineExecutions)
+
+	if len(pipelineExecutions) == 0 {
+		return concourse.CheckResponse{}, nil
+	}
+
+	sort.Slice(pipelineExecutions, func(i, j int) bool {
+		return positionOf(pipelineExecutions[i]).before(positionOf(pipelineExecutions[j]))
+	})
+
+	// Without a cursor only the latest execution is emitted, as Concourse expects on the first check.
+	if !hasCursor {
+		latest := pipelineExecutions[len(pipelineExecutions)-1]
+		return concourse.CheckResponse{latest.Version()}, nil
+	}
+
+	// The cursor itself is emitted while it still passes the filters, followed by every newer
+	// execution, so none is lost when the cursor no longer does.
+	res := concourse.CheckResponse{}
+	for _, execution := range pipelineExecutions {
+		if !positionOf(execution).before(cursor) {
+			res = append(res, execution.Version())
+		}
+	}
+	return res, nil
+}
+
+// position orders executions by start time, and by id when they started at the same time.
+type position struct {
+	startTime int64
+	id        string
+}
+
+func positionOf(execution spinnaker.PipelineExecution) position {
+	return position{startTime: execution.StartTime, id: execution.ID}
+}
+
+func (p position) before(other position) bool {
+	if p.startTime != other.startTime {
+		return p.startTime < other.startTime
+	}
+	return p.id < other.id
+}
+
+// cursorPosition finds the position of the previous version among the fetched executions, or
+// from its start_time if it is not among them anymore.
+func cursorPosition(version concourse.Version, pes []spinnaker.PipelineExecution) (position, bool) {
+	if version.Ref == "" {
+		return position{}, false
+	}
+	for _, pipeExec := range pes {
+		if pipeExec.ID == version.Ref && pipeExec.StartTime > 0 {
+			return positionOf(pipeExec), true
+		}
+	}
+	startTime, err := strconv.ParseInt(version.StartTime, 10, 64)
+	if err != nil {
+		return position{}, false
+	}
+	return position{startTime: startTime, id: version.Ref}, true
+}
+
+func containsExecution(pes []spinnaker.PipelineExecution, id string) bool {
+	for _, pipeExec := range pes {
+		if pipeExec.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// filterStarted drops the executions that have not started yet. They are emitted once they
+// have a start time, so their version never changes.
+func filterStarted(pes []spinnaker.PipelineExecution) []spinnaker.PipelineExecution {
+	pe := make([]spinnaker.PipelineExecution, 0)
+	for _, pipeExec := range pes {
+		if pipeExec.StartTime > 0 {
+			pe = append(pe, pipeExec)
+		}
+	}
+	return pe
+}
+
+// filterPipeline keeps the executions of the pipeline config, whatever the pipeline was named
+// when they ran. Executions without a pipelineConfigId are matched by name.
+func filterPipeline(config spinnaker.PipelineConfig, pes []spinnaker.PipelineExecution) []spinnaker.PipelineExecution {
+	pe := make([]spinnaker.PipelineExecution, 0)
+	for _, pipeExec := range pes {
+		matches := pipeExec.Name == config.Name
+		if pipeExec.PipelineConfigID != "" {
+			matches = pipeExec.PipelineConfigID == config.ID
+		}
+		if matches {
+			pe = append(pe, pipeExec)
+		}
+	}
+	return pe
+}
+
+// allowed reports whether value is one of the allowed values, or if any value is allowed.
+func allowed(value string, values []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, currValue := range values {
+		if value == currValue {
+			return true
+		}
+	}
+	return false
+}
+
+func filterStatus(statuses []string, pes []spinnaker.PipelineExecution) []spinnaker.PipelineExecution {
+	pe := make([]spinnaker.PipelineExecution, 0)
+	for _, pipeExec := range pes {
+		if allowed(pipeExec.Status, statuses) {
+			pe = append(pe, pipeExec)
+		}
+	}
+	return pe
+}
+
+// filterTrigger keeps the executions triggered by one of trigger_types and trigger_users and,
+// with ignore_self_triggered, drops those triggered by a put of this resource type, from any
+// Concourse team or pipeline.
+func filterTrigger(source concourse.Source, pes []spinnaker.PipelineExecution) []spinnaker.PipelineExecution {
+	pe := make([]spinnaker.PipelineExecution, 0)
+	for _, pipeExec := range pes {
+		if source.IgnoreSelfTriggered && pipeExec.Trigger.Type == trigger.Type {
+			continue
+		}
+		if allowed(pipeExec.Trigger.Type, source.TriggerTypes) && allowed(pipeExec.Trigger.User, source.TriggerUsers) {
+			pe = append(pe, pipeExec)
+		}
+	}
+	return pe
+}
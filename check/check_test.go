@@ -0,0 +1,334 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package check_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/hellofresh/spinnaker-resource/check"
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+var _ = Describe("Run", func() {
+	var (
+		gateServer     *ghttp.Server
+		request        concourse.CheckRequest
+		stdin          *bytes.Buffer
+		stdout, stderr *bytes.Buffer
+		runErr         error
+	)
+
+	BeforeEach(func() {
+		gateServer = ghttp.NewServer()
+		request = concourse.CheckRequest{
+			Source: concourse.Source{
+				SpinnakerAPI:         gateServer.URL(),
+				SpinnakerApplication: "bar",
+				SpinnakerPipeline:    "foo",
+				Auth: concourse.Auth{
+					Type:     "basic",
+					Username: "user",
+					Password: "password",
+				},
+			},
+		}
+		stdin = nil
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		if stdin == nil {
+			marshalledRequest, err := json.Marshal(request)
+			Expect(err).ToNot(HaveOccurred())
+			stdin = bytes.NewBuffer(marshalledRequest)
+		}
+		runErr = check.Run(stdin, stdout, stderr, []string{"check"})
+	})
+
+	Context("when the request cannot be decoded", func() {
+		BeforeEach(func() {
+			stdin = bytes.NewBufferString("{")
+		})
+
+		It("returns a step error without writing a response", func() {
+			var stepErr *concourse.StepError
+			Expect(errors.As(runErr, &stepErr)).To(BeTrue())
+			Expect(stepErr.Doing).To(Equal("reading request"))
+			Expect(stdout.Len()).To(BeZero())
+		})
+	})
+
+	Context("when the application does not exist", func() {
+		BeforeEach(func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(404, map[string]interface{}{"error": "Not Found"}),
+			)
+		})
+
+		It("returns a check step error", func() {
+			Expect(runErr).To(MatchError("check step failed: spinnaker application bar not found"))
+			Expect(stdout.Len()).To(BeZero())
+		})
+	})
+
+	Context("when there are no pipeline executions", func() {
+		BeforeEach(func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=25"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{}),
+				),
+			)
+		})
+
+		It("writes an empty list of versions", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).To(MatchJSON("[]"))
+		})
+	})
+
+	Context("when the executions have different triggers", func() {
+		BeforeEach(func() {
+			request.Source.SpinnakerStage = "1"
+			request.Source.Statuses = []string{"SUCCEEDED"}
+
+			execution := func(id string, startTime int, triggerType, user string) map[string]interface{} {
+				return map[string]interface{}{
+					"id":        id,
+					"name":      "foo",
+					"status":    "SUCCEEDED",
+					"startTime": startTime,
+					"trigger":   map[string]interface{}{"type": triggerType, "user": user},
+					"stages":    []map[string]interface{}{{"refId": "1", "status": "SUCCEEDED"}},
+				}
+			}
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+					execution("EX4", 4, "concourse-resource", "concourse"),
+					execution("EX3", 3, "git", "alice"),
+					execution("EX2", 2, "cron", "[anonymous]"),
+					execution("EX1", 1, "manual", "bob"),
+				}),
+			)
+		})
+
+		It("returns the latest execution, whatever its trigger", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX4", "start_time": "4"}]`))
+		})
+
+		Context("when ignore_self_triggered is set", func() {
+			BeforeEach(func() {
+				request.Source.IgnoreSelfTriggered = true
+			})
+
+			It("ignores the executions triggered by the resource", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX3", "start_time": "3"}]`))
+			})
+		})
+
+		Context("when trigger_types is set", func() {
+			BeforeEach(func() {
+				request.Source.TriggerTypes = []string{"manual", "cron"}
+			})
+
+			It("only returns executions with one of those trigger types", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX2", "start_time": "2"}]`))
+			})
+		})
+
+		Context("when trigger_users is set", func() {
+			BeforeEach(func() {
+				request.Source.TriggerUsers = []string{"alice", "bob"}
+				request.Version.Ref = "EX1"
+			})
+
+			It("only returns executions triggered by those users", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX1", "start_time": "1"}, {"ref": "EX3", "start_time": "3"}]`))
+			})
+		})
+	})
+
+	Context("when spinnaker_stages selects several stages of an execution", func() {
+		BeforeEach(func() {
+			request.Source.SpinnakerStages = []string{"type:manualJudgment", "name:Deploy to prod"}
+			request.Source.Statuses = []string{"RUNNING", "SUCCEEDED"}
+			request.Version.Ref = "EX1"
+
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+					{
+						"id": "EX3", "name": "foo", "status": "RUNNING", "startTime": 3,
+						"stages": []map[string]interface{}{{"refId": "1", "type": "bake", "name": "Bake", "status": "RUNNING"}},
+					},
+					{
+						"id": "EX2", "name": "foo", "status": "SUCCEEDED", "startTime": 2,
+						"stages": []map[string]interface{}{
+							{"refId": "1", "type": "manualJudgment", "name": "Approve", "status": "SUCCEEDED"},
+							{"refId": "2", "type": "deploy", "name": "Deploy to prod", "status": "SUCCEEDED"},
+						},
+					},
+					{
+						"id": "EX1", "name": "foo", "status": "RUNNING", "startTime": 1,
+						"stages": []map[string]interface{}{{"refId": "2", "type": "deploy", "name": "Deploy to prod", "status": "RUNNING"}},
+					},
+				}),
+			)
+		})
+
+		It("returns each execution with a selected stage once", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX1", "start_time": "1"}, {"ref": "EX2", "start_time": "2"}]`))
+		})
+	})
+
+	Context("when ordering executions by start time", func() {
+		BeforeEach(func() {
+			request.Source.Statuses = []string{"NOT_STARTED", "SUCCEEDED"}
+
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}),
+				// EX6 has not started yet, so it has no start time to order it by.
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+					{"id": "EX6", "name": "foo", "status": "NOT_STARTED", "buildTime": 5000},
+					{"id": "EX5", "name": "foo", "status": "SUCCEEDED", "buildTime": 1000, "startTime": 4000},
+					{"id": "EX4", "name": "foo", "status": "SUCCEEDED", "buildTime": 2000, "startTime": 3000},
+					{"id": "EX3", "name": "foo", "status": "SUCCEEDED", "buildTime": 3000, "startTime": 3000},
+					{"id": "EX2", "name": "foo", "status": "TERMINAL", "buildTime": 4000, "startTime": 2000},
+					{"id": "EX1", "name": "foo", "status": "SUCCEEDED", "buildTime": 5000, "startTime": 1000},
+				}),
+			)
+		})
+
+		Context("when the previous version is among the executions", func() {
+			BeforeEach(func() {
+				request.Version = concourse.Version{Ref: "EX1", StartTime: "1000"}
+			})
+
+			It("returns it and every execution that started after it, by start time and id", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[
+					{"ref": "EX1", "start_time": "1000"},
+					{"ref": "EX3", "start_time": "3000"},
+					{"ref": "EX4", "start_time": "3000"},
+					{"ref": "EX5", "start_time": "4000"}
+				]`))
+			})
+		})
+
+		Context("when the previous version does not match the filters anymore", func() {
+			BeforeEach(func() {
+				request.Version = concourse.Version{Ref: "EX2", StartTime: "2000"}
+			})
+
+			It("returns every execution that started after it", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[
+					{"ref": "EX3", "start_time": "3000"},
+					{"ref": "EX4", "start_time": "3000"},
+					{"ref": "EX5", "start_time": "4000"}
+				]`))
+			})
+		})
+
+		Context("when the previous version is not among the executions", func() {
+			BeforeEach(func() {
+				request.Version = concourse.Version{Ref: "EX0", StartTime: "3000"}
+			})
+
+			It("returns every execution that started after its start time", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[
+					{"ref": "EX3", "start_time": "3000"},
+					{"ref": "EX4", "start_time": "3000"},
+					{"ref": "EX5", "start_time": "4000"}
+				]`))
+			})
+		})
+
+		Context("when there is no previous version", func() {
+			It("returns the execution that started last", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX5", "start_time": "4000"}]`))
+			})
+		})
+	})
+
+	Context("when the previous version is older than the widest window", func() {
+		BeforeEach(func() {
+			request.Source.ExecutionLimit = spinnaker.MaxExecutionWindow
+			request.Version = concourse.Version{Ref: "EX-OLD"}
+
+			executions := make([]map[string]interface{}, spinnaker.MaxExecutionWindow)
+			for i := range executions {
+				executions[i] = map[string]interface{}{"id": fmt.Sprintf("EX%d", i), "name": "foo", "status": "SUCCEEDED", "startTime": 1000 + i}
+			}
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=1000"),
+					ghttp.RespondWithJSONEncoded(200, executions),
+				),
+			)
+		})
+
+		It("says that older executions are not emitted", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stderr.String()).To(ContainSubstring("Previous version EX-OLD is not among the 1000 most recent executions, executions older than those are not emitted"))
+		})
+	})
+
+	Context("when the pipeline was renamed", func() {
+		BeforeEach(func() {
+			request.Source.SpinnakerPipeline = ""
+			request.Source.SpinnakerPipelineID = "PC1"
+			request.Version = concourse.Version{Ref: "EX1", StartTime: "1"}
+
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}, {"id": "PC2", "name": "old-foo"}}),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+					{"id": "EX3", "name": "foo", "pipelineConfigId": "PC1", "status": "SUCCEEDED", "startTime": 3},
+					{"id": "EX2", "name": "old-foo", "pipelineConfigId": "PC2", "status": "SUCCEEDED", "startTime": 2},
+					{"id": "EX1", "name": "old-foo", "pipelineConfigId": "PC1", "status": "SUCCEEDED", "startTime": 1},
+				}),
+			)
+		})
+
+		It("returns the executions of the pipeline config that ran under its old name", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).To(MatchJSON(`[{"ref": "EX1", "start_time": "1"}, {"ref": "EX3", "start_time": "3"}]`))
+		})
+	})
+})
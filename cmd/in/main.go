@@ -1,108 +1,21 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
-	"sync"
-	"time"
 
 	"github.com/hellofresh/spinnaker-resource/concourse"
-	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	"github.com/hellofresh/spinnaker-resource/in"
 )
 
 func main() {
-
-	if len(os.Args) < 2 {
-		concourse.Fatal("get step failed", fmt.Errorf("destination path not specified"))
-	}
-
-	var request concourse.InRequest
-	concourse.ReadRequest(&request)
-
-	spinClient, err := spinnaker.NewClient(request.Source)
-	if err != nil {
-		concourse.Fatal("get step failed", err)
-	}
-
-	res, err := spinClient.GetPipelineExecutionRaw(request.Version.Ref)
-	if err != nil {
-		concourse.Fatal("get step failed", err)
-	}
-
-	dest := os.Args[1]
-
-	err = ioutil.WriteFile(filepath.Join(dest, "metadata.json"), res, 0644)
-	if err != nil {
-		concourse.Fatal("get step failed", err)
-	}
-
-	err = ioutil.WriteFile(filepath.Join(dest, "version"), []byte(request.Version.Ref), 0644)
-	if err != nil {
-		concourse.Fatal("get step failed", err)
-	}
-
-	var metaData concourse.IntermediateMetadata
-	err = json.Unmarshal(res, &metaData)
-	if err != nil {
-		concourse.Fatal("get step failed", err)
-	}
-
-	var stageId string
-	var stageLock sync.RWMutex
-	for _, stage := range metaData.Stages {
-		stageLock.Lock()
-		status := spinnaker.InStatuses(stage.Status, request.Source.Statuses)
-		stageLock.Unlock()
-		if stage.Type == "concourse" && status {
-			stageId = stage.ID
-			break
-		}
-	}
-
-	if stageId == "" {
-		concourse.Fatal("Concourse stage not found", nil)
-	}
-
-	resArr := []concourse.InResponseMetadata{
-		concourse.InResponseMetadata{
-			Name:  "Application Name",
-			Value: metaData.ApplicationName,
-		},
-		concourse.InResponseMetadata{
-			Name:  "Pipeline Name",
-			Value: metaData.PipelineName,
-		},
-		concourse.InResponseMetadata{
-			Name:  "Status",
-			Value: metaData.Status,
-		},
-		concourse.InResponseMetadata{
-			Name:  "Start time",
-			Value: time.Unix(metaData.StartTime/1000, 0).Format(time.UnixDate),
-		},
-		concourse.InResponseMetadata{
-			Name:  "End time",
-			Value: time.Unix(metaData.EndTime/1000, 0).Format(time.UnixDate),
-		},
-		concourse.InResponseMetadata{
-			Name:  "Stage Id",
-			Value: stageId,
-		},
-	}
-
-	InResponse := concourse.InResponse{
-		Version:  request.Version,
-		Metadata: resArr,
-	}
-
-	err = spinClient.NotifyConcourseExecution(stageId)
-	if err != nil {
-		concourse.Fatal("Notify Concourse Execution failed", err)
-	}
-
-	concourse.WriteResponse(InResponse)
-
+	os.Exit(concourse.Exit(os.Stderr, in.Run(os.Stdin, os.Stdout, os.Stderr, os.Args)))
 }
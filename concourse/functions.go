@@ -12,32 +12,58 @@ package concourse
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/mitchellh/colorstring"
 )
 
-func Fatal(doing string, err error) {
-	Sayf(colorstring.Color("[red]error %s: %s\n"), doing, err)
-	//TODO: don't exit here, let the caller decide.
-	os.Exit(1)
+// StepError is returned by a command when one of its steps fails. Doing describes
+// the step, e.g. "put step failed", and Err the underlying cause.
+type StepError struct {
+	Doing string
+	Err   error
 }
 
-func Sayf(message string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, message, args...)
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Doing, e.Err)
 }
 
-//TODO refactor this and don't exit in this function, instead return control to caller with err msg
-func ReadRequest(request interface{}) {
-	if err := json.NewDecoder(os.Stdin).Decode(request); err != nil {
-		Fatal("Error reading request: %v\n", err)
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// Fail wraps err in a StepError describing what the command was doing.
+func Fail(doing string, err error) error {
+	return &StepError{Doing: doing, Err: err}
+}
+
+// Exit reports err, if any, on stderr and returns the exit code a command should
+// terminate with. It is meant to be the single exit point of each main.
+func Exit(stderr io.Writer, err error) int {
+	if err == nil {
+		return 0
+	}
+	Sayf(stderr, colorstring.Color("[red]error %s\n"), err)
+	return 1
+}
+
+func Sayf(w io.Writer, message string, args ...interface{}) {
+	fmt.Fprintf(w, message, args...)
+}
+
+func ReadRequest(r io.Reader, request interface{}) error {
+	// Numbers in params are kept as written, so ids beyond the precision of a float64 are not changed.
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(request); err != nil {
+		return Fail("reading request", err)
 	}
+	return nil
 }
 
-//TODO refactor this and don't exit in this function, instead return control to caller with err msg
-func WriteResponse(response interface{}) {
-	if err := json.NewEncoder(os.Stdout).Encode(response); err != nil {
-		Fatal("Error writing response: %v\n", err)
+func WriteResponse(w io.Writer, response interface{}) error {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		return Fail("writing response", err)
 	}
-	os.Exit(0)
+	return nil
 }
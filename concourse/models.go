@@ -11,18 +11,48 @@ package concourse
 
 type Source struct {
 	SpinnakerAPI         string   `json:"spinnaker_api"`
+	SpinnakerUIURL       string   `json:"spinnaker_ui_url"`
 	SpinnakerApplication string   `json:"spinnaker_application"`
 	SpinnakerPipeline    string   `json:"spinnaker_pipeline"`
+	SpinnakerPipelineID  string   `json:"spinnaker_pipeline_id"`
 	SpinnakerStage       string   `json:"spinnaker_stage"`
+	SpinnakerStages      []string `json:"spinnaker_stages"`
+	ExecutionLimit       int      `json:"execution_limit"`
 	Statuses             []string `json:"statuses"`
+	IgnoreSelfTriggered  bool     `json:"ignore_self_triggered"`
+	TriggerTypes         []string `json:"trigger_types"`
+	TriggerUsers         []string `json:"trigger_users"`
 	StatusCheckTimeout   string   `json:"status_check_timeout"`
 	StatusCheckInterval  string   `json:"status_check_interval"`
+	RequestTimeout       string   `json:"request_timeout"`
+	Retries              int      `json:"retries"`
+	RetryBackoff         string   `json:"retry_backoff"`
+	RetryMaxBackoff      string   `json:"retry_max_backoff"`
 	X509Cert             string   `json:"spinnaker_x509_cert"`
 	X509Key              string   `json:"spinnaker_x509_key"`
+	CACert               string   `json:"spinnaker_ca_cert"`
+	ServerName           string   `json:"spinnaker_server_name"`
+	SkipTLSVerify        bool     `json:"skip_tls_verify"`
+	Auth                 Auth     `json:"auth"`
 }
 
+type Auth struct {
+	Type         string   `json:"type"`
+	Username     string   `json:"username"`
+	Password     string   `json:"password"`
+	Token        string   `json:"token"`
+	TokenURL     string   `json:"token_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	CACert       string   `json:"ca_cert"`
+}
+
+// Version identifies a pipeline execution by its id and, once it started, its start time in
+// milliseconds since the epoch, by which check orders versions.
 type Version struct {
-	Ref string `json:"ref"`
+	Ref       string `json:"ref"`
+	StartTime string `json:"start_time,omitempty"`
 }
 
 type MetadataPair struct {
@@ -31,19 +61,60 @@ type MetadataPair struct {
 }
 
 type OutParams struct {
-	TriggerParams             map[string]string `json:"trigger_params,omitempty"` // optional
-	Artifacts                 string            `json:"artifacts_json_file"`      // optional
-	TriggerParamsJSONFilePath string            `json:"trigger_params_json_file"` //optional
+	TriggerParams               map[string]interface{} `json:"trigger_params,omitempty"`                 // optional
+	Artifacts                   string                 `json:"artifacts_json_file"`                      // optional
+	TriggerParamsJSONFilePath   string                 `json:"trigger_params_json_file"`                 //optional
+	OnTimeout                   string                 `json:"on_timeout,omitempty"`                     // optional
+	OnAbort                     string                 `json:"on_abort,omitempty"`                       // optional
+	WaitForStage                *StageCondition        `json:"wait_for_stage,omitempty"`                 // optional
+	DryRun                      bool                   `json:"dry_run,omitempty"`                        // optional
+	SkipTriggerParamsValidation bool                   `json:"skip_trigger_params_validation,omitempty"` // optional
+	StrictTemplates             bool                   `json:"strict_templates,omitempty"`               // optional
+	ArtifactSpecs               []ArtifactSpec         `json:"artifacts,omitempty"`                      // optional
+	ReportStage                 *ReportStage           `json:"report_stage,omitempty"`                   // optional
+}
+
+// ReportStage attaches the result of the build to the context of the concourse stage of the
+// execution a get step fetched into Dir, instead of triggering the pipeline.
+type ReportStage struct {
+	Dir            string `json:"dir"`
+	Status         string `json:"status,omitempty"`
+	PropertiesFile string `json:"properties_file,omitempty"`
+}
+
+// ArtifactSpec declares a Spinnaker artifact to trigger the pipeline with. Which fields are
+// required depends on the type.
+type ArtifactSpec struct {
+	Type            string `json:"type"`
+	Name            string `json:"name,omitempty"`
+	Version         string `json:"version,omitempty"`
+	Reference       string `json:"reference,omitempty"`
+	Location        string `json:"location,omitempty"`
+	ArtifactAccount string `json:"artifact_account,omitempty"`
+	ImageDir        string `json:"image_dir,omitempty"` // docker/image: directory of a registry-image resource
+	File            string `json:"file,omitempty"`      // embedded/base64: file to embed
+}
+
+type StageCondition struct {
+	RefID    string   `json:"ref_id,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Statuses []string `json:"statuses,omitempty"`
 }
 
 type CheckRequest struct {
 	Source  Source `json:"source"`
 	Version `json:"version"`
 }
+type InParams struct {
+	OutputsEnv     map[string]string `json:"outputs_env,omitempty"`     // optional
+	FetchArtifacts bool              `json:"fetch_artifacts,omitempty"` // optional
+	NotifyStage    string            `json:"notify_stage,omitempty"`    // optional
+}
+
 type InRequest struct {
-	Source  Source    `json:"source"`
-	Version Version   `json:"version"`
-	Params  OutParams `json:"params"`
+	Source  Source   `json:"source"`
+	Version Version  `json:"version"`
+	Params  InParams `json:"params"`
 }
 type OutRequest struct {
 	Source Source    `json:"source"`
@@ -62,20 +133,6 @@ type InResponseMetadata struct {
 	Value string `json:"value"`
 }
 
-type IntermediateMetadata struct {
-	PipelineName    string `json:"name"`
-	ApplicationName string `json:"application"`
-	StartTime       int64  `json:"startTime"`
-	EndTime         int64  `json:"endTime"`
-	Status          string `json:"status"`
-	Stages          []struct {
-		ID     string `json:"id"`
-		Type   string `json:"type"`
-		Name   string `json:"name"`
-		Status string `json:"status"`
-	}
-}
-
 type InResponse struct {
 	Version  `json:"version"`
 	Metadata []InResponseMetadata `json:"metadata"`
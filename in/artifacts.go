@@ -0,0 +1,150 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+const defaultFetchTimeout = 10 * time.Minute
+
+// ArtifactFetcher downloads the content of an artifact stored outside of the execution.
+type ArtifactFetcher interface {
+	// Fetch returns the content of the artifact, or ok false if it can't fetch artifacts of its type.
+	Fetch(artifact spinnaker.Artifact) (content io.ReadCloser, ok bool, err error)
+}
+
+// HTTPFetcher fetches http/file artifacts from the URL in their reference.
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+// NewHTTPFetcher returns an HTTPFetcher with a default timeout.
+func NewHTTPFetcher() HTTPFetcher {
+	return HTTPFetcher{Client: &http.Client{Timeout: defaultFetchTimeout}}
+}
+
+func (f HTTPFetcher) Fetch(artifact spinnaker.Artifact) (io.ReadCloser, bool, error) {
+	if artifact.Type != "http/file" {
+		return nil, false, nil
+	}
+	resp, err := f.Client.Get(artifact.Reference)
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, true, fmt.Errorf("GET %s responded with status code: %d", artifact.Reference, resp.StatusCode)
+	}
+	return resp.Body, true, nil
+}
+
+// artifactIndexEntry describes an artifact in artifacts/index.json. Path is relative to the
+// destination and empty when the artifact content could not be fetched.
+type artifactIndexEntry struct {
+	spinnaker.Artifact
+	Path string `json:"path,omitempty"`
+}
+
+// fetchArtifacts writes the content of the execution artifacts to artifacts/files, decoding
+// embedded/base64 artifacts and downloading the others through the fetcher, and lists every
+// artifact in artifacts/index.json.
+func fetchArtifacts(dest string, execution spinnaker.PipelineExecution, fetcher ArtifactFetcher) error {
+	filesDir := filepath.Join(dest, "artifacts", "files")
+	if err := os.MkdirAll(filesDir, 0755); err != nil {
+		return err
+	}
+
+	index := []artifactIndexEntry{}
+	for i, artifact := range execution.Artifacts() {
+		entry := artifactIndexEntry{Artifact: artifact}
+
+		content, err := artifactContent(artifact, fetcher)
+		if err != nil {
+			return fmt.Errorf("fetching artifact %s: %s", describeArtifact(artifact), err)
+		}
+		if content != nil {
+			entry.Path = path.Join("artifacts", "files", fmt.Sprintf("%d-%s", i, artifactFileName(artifact)))
+			err = writeContent(filepath.Join(dest, filepath.FromSlash(entry.Path)), content)
+			if err != nil {
+				return fmt.Errorf("fetching artifact %s: %s", describeArtifact(artifact), err)
+			}
+		}
+
+		index = append(index, entry)
+	}
+
+	return writeJSON(filepath.Join(dest, "artifacts", "index.json"), index)
+}
+
+func artifactContent(artifact spinnaker.Artifact, fetcher ArtifactFetcher) (io.ReadCloser, error) {
+	if artifact.Type == "embedded/base64" {
+		content, err := base64.StdEncoding.DecodeString(artifact.Reference)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	}
+
+	content, ok, err := fetcher.Fetch(artifact)
+	if !ok {
+		return nil, nil
+	}
+	return content, err
+}
+
+func writeContent(path string, content io.ReadCloser) error {
+	defer content.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// artifactFileName derives a file name from the artifact name, or its reference if it has none.
+func artifactFileName(artifact spinnaker.Artifact) string {
+	name := artifact.Name
+	if name == "" && artifact.Type != "embedded/base64" {
+		name = artifact.Reference
+	}
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	name = path.Base(strings.TrimRight(name, "/"))
+	if name == "" || name == "." || name == "/" {
+		return "artifact"
+	}
+	return name
+}
+
+func describeArtifact(artifact spinnaker.Artifact) string {
+	if artifact.Name != "" {
+		return fmt.Sprintf("%s %s", artifact.Type, artifact.Name)
+	}
+	return artifact.Type
+}
@@ -0,0 +1,177 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// writeExecutionFiles explodes the execution into files under dest, so tasks can read
+// individual values without parsing metadata.json:
+//
+//	trigger.json
+//	parameters.json
+//	stages/<refId>/context.json
+//	stages/<refId>/outputs.json
+//	artifacts/<refId>-<n>.json
+func writeExecutionFiles(dest string, raw []byte, execution spinnaker.PipelineExecution) error {
+	var rawExecution struct {
+		Trigger json.RawMessage `json:"trigger"`
+	}
+	if err := json.Unmarshal(raw, &rawExecution); err != nil {
+		return err
+	}
+	trigger := []byte(rawExecution.Trigger)
+	if len(trigger) == 0 {
+		trigger = []byte("{}")
+	}
+	if err := ioutil.WriteFile(filepath.Join(dest, "trigger.json"), trigger, 0644); err != nil {
+		return err
+	}
+
+	parameters := execution.Trigger.Parameters
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	if err := writeJSON(filepath.Join(dest, "parameters.json"), parameters); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(dest, "artifacts"), 0755); err != nil {
+		return err
+	}
+
+	for _, stage := range execution.Stages {
+		stageDir := filepath.Join(dest, "stages", fileName(stage.RefID))
+		if err := os.MkdirAll(stageDir, 0755); err != nil {
+			return err
+		}
+		if err := writeJSON(filepath.Join(stageDir, "context.json"), emptyIfNil(stage.Context)); err != nil {
+			return err
+		}
+		if err := writeJSON(filepath.Join(stageDir, "outputs.json"), emptyIfNil(stage.Outputs)); err != nil {
+			return err
+		}
+
+		artifacts, _ := stage.Outputs["artifacts"].([]interface{})
+		for i, artifact := range artifacts {
+			path := filepath.Join(dest, "artifacts", fmt.Sprintf("%s-%d.json", fileName(stage.RefID), i))
+			if err := writeJSON(path, artifact); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeOutputsEnv writes outputs.env, assigning each variable the stage output selected by
+// "<refId>.<key>[.<key>...]". Keys are looked up in the stage outputs, then in its context.
+// Values are single quoted, so the file can be sourced by a shell.
+func writeOutputsEnv(path string, selectors map[string]string, execution spinnaker.PipelineExecution) error {
+	names := make([]string, 0, len(selectors))
+	for name := range selectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var env strings.Builder
+	for _, name := range names {
+		value, err := selectOutput(selectors[name], execution)
+		if err != nil {
+			return fmt.Errorf("outputs_env %s: %s", name, err)
+		}
+		fmt.Fprintf(&env, "%s=%s\n", name, shellQuote(value))
+	}
+	return ioutil.WriteFile(path, []byte(env.String()), 0644)
+}
+
+func selectOutput(selector string, execution spinnaker.PipelineExecution) (string, error) {
+	parts := strings.Split(selector, ".")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid selector %q, must be <refId>.<key>", selector)
+	}
+
+	for _, stage := range execution.Stages {
+		if stage.RefID != parts[0] {
+			continue
+		}
+		for _, values := range []map[string]interface{}{stage.Outputs, stage.Context} {
+			if value, ok := lookup(values, parts[1:]); ok {
+				return stringify(value)
+			}
+		}
+		return "", fmt.Errorf("stage %s has no output %s", parts[0], strings.Join(parts[1:], "."))
+	}
+	return "", fmt.Errorf("stage %s not found", parts[0])
+}
+
+// lookup walks path through nested objects and arrays, where array elements are selected by index.
+func lookup(value interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			next, ok := current[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, false
+			}
+			value = current[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func stringify(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	bytes, err := json.Marshal(value)
+	return string(bytes), err
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+func writeJSON(path string, v interface{}) error {
+	bytes, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, bytes, 0644)
+}
+
+func emptyIfNil(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return map[string]interface{}{}
+	}
+	return values
+}
+
+// fileName makes a stage refId safe to use as a file name.
+func fileName(refID string) string {
+	return strings.Replace(refID, string(filepath.Separator), "_", -1)
+}
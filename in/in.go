@@ -0,0 +1,193 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// When to notify the concourse stage of the execution that the build started.
+const (
+	notifyStageAuto   = "auto"
+	notifyStageAlways = "always"
+	notifyStageNever  = "never"
+)
+
+// Run executes the get step, fetching the requested pipeline execution into the
+// destination directory given as the first argument.
+func Run(stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	return RunWithFetcher(stdin, stdout, stderr, args, NewHTTPFetcher())
+}
+
+// RunWithFetcher executes the get step like Run, downloading artifacts with fetcher when
+// fetch_artifacts is set. A nil fetcher fetches http/file artifacts only.
+func RunWithFetcher(stdin io.Reader, stdout, stderr io.Writer, args []string, fetcher ArtifactFetcher) error {
+	if fetcher == nil {
+		fetcher = NewHTTPFetcher()
+	}
+
+	if len(args) < 2 {
+		return concourse.Fail("get step failed", errors.New("destination path not specified"))
+	}
+
+	var request concourse.InRequest
+	if err := concourse.ReadRequest(stdin, &request); err != nil {
+		return err
+	}
+
+	notifyStage := request.Params.NotifyStage
+	if notifyStage == "" {
+		notifyStage = notifyStageAuto
+	}
+	if notifyStage != notifyStageAuto && notifyStage != notifyStageAlways && notifyStage != notifyStageNever {
+		return concourse.Fail("get step failed", fmt.Errorf("notify_stage must be one of %s, %s or %s, got %s", notifyStageAuto, notifyStageAlways, notifyStageNever, notifyStage))
+	}
+
+	spinClient, err := spinnaker.NewClient(request.Source)
+	if err != nil {
+		return concourse.Fail("get step failed", err)
+	}
+
+	res, err := spinClient.GetPipelineExecutionRaw(request.Version.Ref)
+	if err != nil {
+		return concourse.Fail("get step failed", err)
+	}
+
+	dest := args[1]
+
+	err = ioutil.WriteFile(filepath.Join(dest, "metadata.json"), res, 0644)
+	if err != nil {
+		return concourse.Fail("get step failed", err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dest, "version"), []byte(request.Version.Ref), 0644)
+	if err != nil {
+		return concourse.Fail("get step failed", err)
+	}
+
+	metaData, err := spinnaker.DecodePipelineExecution(res)
+	if err != nil {
+		return concourse.Fail("get step failed", err)
+	}
+
+	err = writeExecutionFiles(dest, res, metaData)
+	if err != nil {
+		return concourse.Fail("get step failed", err)
+	}
+
+	if len(request.Params.OutputsEnv) > 0 {
+		err = writeOutputsEnv(filepath.Join(dest, "outputs.env"), request.Params.OutputsEnv, metaData)
+		if err != nil {
+			return concourse.Fail("get step failed", err)
+		}
+	}
+
+	if request.Params.FetchArtifacts {
+		err = fetchArtifacts(dest, metaData, fetcher)
+		if err != nil {
+			return concourse.Fail("get step failed", err)
+		}
+	}
+
+	stage, found := concourseStage(metaData, request.Source.Statuses)
+	if !found && notifyStage == notifyStageAlways {
+		return concourse.Fail("get step failed", errors.New("concourse stage not found"))
+	}
+
+	if found {
+		err = ioutil.WriteFile(filepath.Join(dest, "stage_id"), []byte(stage.ID), 0644)
+		if err != nil {
+			return concourse.Fail("get step failed", err)
+		}
+	}
+
+	resArr := []concourse.InResponseMetadata{
+		concourse.InResponseMetadata{
+			Name:  "Application Name",
+			Value: metaData.Application,
+		},
+		concourse.InResponseMetadata{
+			Name:  "Pipeline Name",
+			Value: metaData.Name,
+		},
+		concourse.InResponseMetadata{
+			Name:  "Status",
+			Value: metaData.Status,
+		},
+		concourse.InResponseMetadata{
+			Name:  "Start time",
+			Value: time.Unix(metaData.StartTime/1000, 0).Format(time.UnixDate),
+		},
+		concourse.InResponseMetadata{
+			Name:  "End time",
+			Value: time.Unix(metaData.EndTime/1000, 0).Format(time.UnixDate),
+		},
+	}
+	if found {
+		resArr = append(resArr, concourse.InResponseMetadata{
+			Name:  "Stage Id",
+			Value: stage.ID,
+		})
+	}
+
+	selectors := spinnaker.StageSelectors(request.Source)
+	if matched, ok := metaData.MatchingStage(selectors, request.Source.Statuses); ok {
+		resArr = append(resArr, concourse.InResponseMetadata{
+			Name:  "Matched stage",
+			Value: describeStage(matched),
+		})
+	}
+
+	InResponse := concourse.InResponse{
+		Version:  request.Version,
+		Metadata: resArr,
+	}
+
+	if found && notifyStage != notifyStageNever {
+		// Concourse gets a version again in every build that uses it and after a put, and the
+		// stage has the build number once it was notified.
+		if buildNumber := stage.Context["buildNumber"]; buildNumber != nil && notifyStage == notifyStageAuto {
+			concourse.Sayf(stderr, "Concourse stage %s was already notified of build %v\n", stage.ID, buildNumber)
+		} else {
+			err = spinClient.NotifyConcourseExecution(stage.ID)
+			if err != nil {
+				return concourse.Fail("notify concourse execution failed", err)
+			}
+		}
+	}
+
+	return concourse.WriteResponse(stdout, InResponse)
+}
+
+func describeStage(stage spinnaker.Stage) string {
+	description := fmt.Sprintf("refId %s, type %s, status %s", stage.RefID, stage.Type, stage.Status)
+	if stage.Name == "" {
+		return description
+	}
+	return fmt.Sprintf("%s (%s)", stage.Name, description)
+}
+
+// concourseStage returns the first concourse stage of the execution in one of the statuses.
+func concourseStage(execution spinnaker.PipelineExecution, statuses []string) (spinnaker.Stage, bool) {
+	for _, stage := range execution.Stages {
+		if stage.Type == "concourse" && spinnaker.InStatuses(stage.Status, statuses) {
+			return stage, true
+		}
+	}
+	return spinnaker.Stage{}, false
+}
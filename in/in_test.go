@@ -0,0 +1,403 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/in"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+var _ = Describe("Run", func() {
+	var (
+		gateServer     *ghttp.Server
+		request        concourse.InRequest
+		dest           string
+		args           []string
+		stdout, stderr *bytes.Buffer
+		fetcher        in.ArtifactFetcher
+		runErr         error
+	)
+
+	BeforeEach(func() {
+		var err error
+		dest, err = ioutil.TempDir("", "in")
+		Expect(err).ToNot(HaveOccurred())
+
+		gateServer = ghttp.NewServer()
+		gateServer.AppendHandlers(
+			ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+			ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "foo"}}),
+		)
+		request = concourse.InRequest{
+			Source: concourse.Source{
+				SpinnakerAPI:         gateServer.URL(),
+				SpinnakerApplication: "bar",
+				SpinnakerPipeline:    "foo",
+				Statuses:             []string{"RUNNING"},
+				Auth: concourse.Auth{
+					Type:     "basic",
+					Username: "user",
+					Password: "password",
+				},
+			},
+			Version: concourse.Version{Ref: "EX1"},
+		}
+		args = []string{"in", dest}
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+		fetcher = nil
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+		os.RemoveAll(dest)
+	})
+
+	JustBeforeEach(func() {
+		stdin, err := json.Marshal(request)
+		Expect(err).ToNot(HaveOccurred())
+		if fetcher != nil {
+			runErr = in.RunWithFetcher(bytes.NewBuffer(stdin), stdout, stderr, args, fetcher)
+		} else {
+			runErr = in.Run(bytes.NewBuffer(stdin), stdout, stderr, args)
+		}
+	})
+
+	Context("when the destination is not given", func() {
+		BeforeEach(func() {
+			args = []string{"in"}
+		})
+
+		It("returns an error", func() {
+			Expect(runErr).To(MatchError("get step failed: destination path not specified"))
+		})
+	})
+
+	Context("when the execution has a running concourse stage", func() {
+		BeforeEach(func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+						"id":          "EX1",
+						"name":        "foo",
+						"application": "bar",
+						"status":      "RUNNING",
+						"trigger": map[string]interface{}{
+							"type":       "concourse-resource",
+							"parameters": map[string]interface{}{"version": "1.2.3"},
+						},
+						"stages": []map[string]interface{}{
+							{
+								"id": "STAGE0", "refId": "0", "type": "bake", "status": "SUCCEEDED",
+								"context": map[string]interface{}{"region": "eu-west-1"},
+								"outputs": map[string]interface{}{
+									"deploymentDetails": []map[string]interface{}{{"ami": "ami-123", "imageName": "it's-baked"}},
+									"artifacts":         []map[string]interface{}{{"type": "aws/image", "reference": "ami-123"}},
+								},
+							},
+							{"id": "STAGE1", "refId": "1", "type": "concourse", "status": "RUNNING"},
+						},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/concourse/stage/start"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+		})
+
+		It("writes the execution to the destination and notifies the stage", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(filepath.Join(dest, "metadata.json")).To(BeAnExistingFile())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+			Expect(gateServer.ReceivedRequests()[3].URL.Query().Get("stageId")).To(Equal("STAGE1"))
+			Expect(readFile(dest, "stage_id")).To(Equal("STAGE1"))
+
+			var response concourse.InResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version.Ref).To(Equal("EX1"))
+		})
+
+		It("writes the trigger, parameters, stage contexts, outputs and artifacts to separate files", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			Expect(readFile(dest, "trigger.json")).To(MatchJSON(`{"type":"concourse-resource","parameters":{"version":"1.2.3"}}`))
+			Expect(readFile(dest, "parameters.json")).To(MatchJSON(`{"version":"1.2.3"}`))
+			Expect(readFile(dest, "stages", "0", "context.json")).To(MatchJSON(`{"region":"eu-west-1"}`))
+			Expect(readFile(dest, "stages", "0", "outputs.json")).To(ContainSubstring(`"ami": "ami-123"`))
+			Expect(readFile(dest, "stages", "1", "context.json")).To(MatchJSON(`{}`))
+			Expect(readFile(dest, "stages", "1", "outputs.json")).To(MatchJSON(`{}`))
+			Expect(readFile(dest, "artifacts", "0-0.json")).To(MatchJSON(`{"type":"aws/image","reference":"ami-123"}`))
+			Expect(filepath.Join(dest, "outputs.env")).ToNot(BeAnExistingFile())
+		})
+
+		Context("when outputs_env is configured", func() {
+			BeforeEach(func() {
+				request.Params.OutputsEnv = map[string]string{
+					"AMI_ID":     "0.deploymentDetails.0.ami",
+					"IMAGE_NAME": "0.deploymentDetails.0.imageName",
+					"REGION":     "0.region",
+				}
+			})
+
+			It("writes the selected stage outputs as shell variables", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(readFile(dest, "outputs.env")).To(Equal("AMI_ID='ami-123'\nIMAGE_NAME='it'\\''s-baked'\nREGION='eu-west-1'\n"))
+			})
+		})
+
+		It("does not name a matched stage without stage selectors", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).ToNot(ContainSubstring("Matched stage"))
+		})
+
+		Context("when spinnaker_stages selects a stage", func() {
+			BeforeEach(func() {
+				request.Source.SpinnakerStages = []string{"name:Bake", "type:concourse"}
+			})
+
+			It("adds the first matched stage in one of the statuses to the metadata", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				var response concourse.InResponse
+				Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+				Expect(response.Metadata).To(ContainElement(concourse.InResponseMetadata{
+					Name:  "Matched stage",
+					Value: "refId 1, type concourse, status RUNNING",
+				}))
+			})
+		})
+
+		Context("when notify_stage is never", func() {
+			BeforeEach(func() {
+				request.Params.NotifyStage = "never"
+			})
+
+			It("writes the stage id without notifying the stage", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+				Expect(readFile(dest, "stage_id")).To(Equal("STAGE1"))
+			})
+		})
+
+		Context("when notify_stage is not a known value", func() {
+			BeforeEach(func() {
+				request.Params.NotifyStage = "true"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("get step failed: notify_stage must be one of auto, always or never, got true"))
+				Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+
+		Context("when fetch_artifacts is not set", func() {
+			It("does not write the artifacts index", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(filepath.Join(dest, "artifacts", "index.json")).ToNot(BeAnExistingFile())
+			})
+		})
+
+		Context("when outputs_env selects an output that does not exist", func() {
+			BeforeEach(func() {
+				request.Params.OutputsEnv = map[string]string{"AMI_ID": "0.deploymentDetails.1.ami"}
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("get step failed: outputs_env AMI_ID: stage 0 has no output deploymentDetails.1.ami"))
+			})
+		})
+	})
+
+	Context("when the concourse stage was already notified", func() {
+		BeforeEach(func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+					"id":     "EX1",
+					"name":   "foo",
+					"status": "RUNNING",
+					"stages": []map[string]interface{}{
+						{"id": "STAGE1", "refId": "1", "type": "concourse", "status": "RUNNING", "context": map[string]interface{}{"buildNumber": 7}},
+					},
+				}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/concourse/stage/start"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+		})
+
+		It("does not notify it again", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+			Expect(stderr.String()).To(ContainSubstring("Concourse stage STAGE1 was already notified of build 7"))
+		})
+
+		Context("when notify_stage is always", func() {
+			BeforeEach(func() {
+				request.Params.NotifyStage = "always"
+			})
+
+			It("notifies it again", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+			})
+		})
+	})
+
+	Context("when the execution has no running concourse stage", func() {
+		BeforeEach(func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+					"id":     "EX1",
+					"name":   "foo",
+					"status": "RUNNING",
+					"stages": []map[string]interface{}{{"id": "STAGE0", "refId": "0", "type": "deploy", "status": "RUNNING"}},
+				}),
+			)
+		})
+
+		It("gets the execution without notifying a stage", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+			Expect(filepath.Join(dest, "metadata.json")).To(BeAnExistingFile())
+			Expect(filepath.Join(dest, "stage_id")).ToNot(BeAnExistingFile())
+			Expect(stdout.String()).ToNot(ContainSubstring("Stage Id"))
+		})
+
+		Context("when notify_stage is always", func() {
+			BeforeEach(func() {
+				request.Params.NotifyStage = "always"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("get step failed: concourse stage not found"))
+			})
+		})
+	})
+
+	Context("when fetch_artifacts is set", func() {
+		var artifactServer *ghttp.Server
+
+		BeforeEach(func() {
+			artifactServer = ghttp.NewServer()
+			request.Params.FetchArtifacts = true
+
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+						"id":     "EX1",
+						"status": "RUNNING",
+						"trigger": map[string]interface{}{
+							"artifacts": []map[string]interface{}{
+								{"type": "embedded/base64", "name": "manifest.yml", "reference": "a2luZDogUG9k"},
+							},
+						},
+						"stages": []map[string]interface{}{
+							{
+								"id": "STAGE0", "refId": "0", "type": "bake", "status": "SUCCEEDED",
+								"outputs": map[string]interface{}{
+									"artifacts": []map[string]interface{}{
+										{"type": "docker/image", "name": "org/app", "reference": "org/app:1.0"},
+									},
+									"resolvedExpectedArtifacts": []map[string]interface{}{
+										{"id": "E1", "boundArtifact": map[string]interface{}{"type": "http/file", "reference": artifactServer.URL() + "/files/values.yml?raw=true"}},
+										{"id": "E2", "boundArtifact": map[string]interface{}{"type": "docker/image", "name": "org/app", "reference": "org/app:1.0"}},
+									},
+								},
+							},
+							{"id": "STAGE1", "refId": "1", "type": "concourse", "status": "RUNNING"},
+						},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/concourse/stage/start"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+		})
+
+		AfterEach(func() {
+			artifactServer.Close()
+		})
+
+		Context("when the artifacts can be fetched", func() {
+			BeforeEach(func() {
+				artifactServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/files/values.yml", "raw=true"),
+						ghttp.RespondWith(200, "replicas: 2"),
+					),
+				)
+			})
+
+			It("writes the artifact contents and an index", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				Expect(readFile(dest, "artifacts", "files", "0-manifest.yml")).To(Equal("kind: Pod"))
+				Expect(readFile(dest, "artifacts", "files", "2-values.yml")).To(Equal("replicas: 2"))
+				Expect(readFile(dest, "artifacts", "index.json")).To(MatchJSON(`[
+					{"type": "embedded/base64", "name": "manifest.yml", "reference": "a2luZDogUG9k", "path": "artifacts/files/0-manifest.yml"},
+					{"type": "docker/image", "name": "org/app", "reference": "org/app:1.0"},
+					{"type": "http/file", "reference": "` + artifactServer.URL() + `/files/values.yml?raw=true", "path": "artifacts/files/2-values.yml"}
+				]`))
+			})
+		})
+
+		Context("when an artifact can't be downloaded", func() {
+			BeforeEach(func() {
+				artifactServer.AppendHandlers(ghttp.RespondWith(404, nil))
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError(MatchRegexp(`^get step failed: fetching artifact http/file: GET .*/files/values.yml\?raw=true responded with status code: 404$`)))
+			})
+		})
+
+		Context("when a fetcher is given", func() {
+			BeforeEach(func() {
+				fetcher = fakeFetcher{"docker/image": "sha256:abc"}
+			})
+
+			It("uses it to fetch artifacts", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(readFile(dest, "artifacts", "files", "1-app")).To(Equal("sha256:abc"))
+			})
+		})
+	})
+})
+
+type fakeFetcher map[string]string
+
+func (f fakeFetcher) Fetch(artifact spinnaker.Artifact) (io.ReadCloser, bool, error) {
+	content, ok := f[artifact.Type]
+	return ioutil.NopCloser(strings.NewReader(content)), ok, nil
+}
+
+func readFile(path ...string) string {
+	bytes, err := ioutil.ReadFile(filepath.Join(path...))
+	Expect(err).ToNot(HaveOccurred())
+	return string(bytes)
+}
@@ -26,6 +26,7 @@ import (
 var _ = Describe("Check", func() {
 	var (
 		applicationName, pipelineName string
+		pipelineConfigID              string
 		responseMap                   []map[string]interface{}
 		input                         concourse.CheckRequest
 		marshalledInput               []byte
@@ -40,35 +41,41 @@ var _ = Describe("Check", func() {
 	)
 	pipelineName = "foo"
 	applicationName = "bar"
+	pipelineConfigID = "f6f11e84-be2c-441a-bd8a-f95827b5590f"
 	pipelineExecutions = []map[string]interface{}{
 		map[string]interface{}{
 			"id":        "EX1",
 			"name":      pipelineName,
 			"buildTime": 1543244670,
+			"startTime": 1543244670000,
 			"status":    "SUCCEEDED",
 		},
 		map[string]interface{}{
 			"id":        "EX2",
 			"name":      pipelineName,
 			"buildTime": 1543244680,
+			"startTime": 1543244680000,
 			"status":    "SUCCEEDED",
 		},
 		map[string]interface{}{
 			"id":        "EX3",
 			"name":      pipelineName,
 			"buildTime": 1543244690,
+			"startTime": 1543244690000,
 			"status":    "TERMINAL",
 		},
 		map[string]interface{}{
 			"id":        "EX4",
 			"name":      "other-pipeline",
 			"buildTime": 1543244690,
+			"startTime": 1543244690000,
 			"status":    "SUCCEEDED",
 		},
 		map[string]interface{}{
 			"id":        "EX5",
 			"name":      "other-pipeline",
 			"buildTime": 1543244690,
+			"startTime": 1543244690000,
 			"status":    "SUCCEEDED",
 		},
 	}
@@ -92,7 +99,7 @@ var _ = Describe("Check", func() {
 				ghttp.RespondWithJSONEncoded(
 					statusCode,
 					[]map[string]string{
-						{"name": pipelineName},
+						{"id": pipelineConfigID, "name": pipelineName},
 					},
 				)),
 			allHandler,
@@ -122,7 +129,7 @@ var _ = Describe("Check", func() {
 		BeforeEach(func() {
 			statusCode = 200
 			allHandler = ghttp.CombineHandlers(
-				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+				ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds="+pipelineConfigID+"&limit=25"),
 				ghttp.RespondWithJSONEncoded(
 					statusCode,
 					pipelineExecutions,
@@ -184,7 +191,7 @@ var _ = Describe("Check", func() {
 						pipelineExecutions[3],
 					}
 					allHandler = ghttp.CombineHandlers(
-						ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+						ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds="+pipelineConfigID+"&limit=25"),
 						ghttp.RespondWithJSONEncoded(
 							statusCode,
 							responseMap,
@@ -218,7 +225,7 @@ var _ = Describe("Check", func() {
 			statuses = []string{}
 			statusCode = 200
 			allHandler = ghttp.CombineHandlers(
-				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+				ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds="+pipelineConfigID+"&limit=25"),
 				ghttp.RespondWithJSONEncoded(
 					statusCode,
 					responseMap,
@@ -263,7 +270,7 @@ var _ = Describe("Check", func() {
 					statusCode = 200
 
 					allHandler = ghttp.CombineHandlers(
-						ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+						ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds="+pipelineConfigID+"&limit=25"),
 						ghttp.RespondWithJSONEncoded(
 							statusCode,
 							[]map[string]interface{}{
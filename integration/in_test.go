@@ -42,6 +42,10 @@ var _ = Describe("In", func() {
 		dir                           string
 	)
 
+	BeforeEach(func() {
+		pipelineName = "foo"
+	})
+
 	JustBeforeEach(func() {
 		spinnakerServer.AppendHandlers(
 			ghttp.CombineHandlers(
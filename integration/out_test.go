@@ -16,6 +16,7 @@ import (
 	"net/http"
 	"os/exec"
 	"strconv"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -49,6 +50,7 @@ var _ = Describe("Out", func() {
 			X509Key:              serverKey,
 		}
 		pipelineExecutionID = "ABC123"
+		inputParams = concourse.OutParams{}
 
 		spinnakerServer.AppendHandlers(
 			ghttp.CombineHandlers(
@@ -68,8 +70,14 @@ var _ = Describe("Out", func() {
 				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+inputSource.SpinnakerApplication+"/pipelineConfigs")),
 				ghttp.RespondWithJSONEncoded(
 					200,
-					[]map[string]string{
-						{"name": pipelineName},
+					[]map[string]interface{}{
+						{
+							"name": pipelineName,
+							"parameterConfig": []map[string]interface{}{
+								{"name": "foo"},
+								{"name": "foobar"},
+							},
+						},
 					},
 				)),
 		)
@@ -84,8 +92,23 @@ var _ = Describe("Out", func() {
 	})
 
 	Context("when Spinnaker responds with a status code 202 accepted pipeline execution", func() {
-		var httpPOSTSuccessHandler http.HandlerFunc
+		var httpPOSTSuccessHandler, executionHandler http.HandlerFunc
 		BeforeEach(func() {
+			executionHandler = ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/pipelines/"+pipelineExecutionID),
+				ghttp.RespondWithJSONEncoded(
+					200,
+					map[string]interface{}{
+						"id":        pipelineExecutionID,
+						"status":    "RUNNING",
+						"startTime": 1543414041364,
+						"trigger": map[string]interface{}{
+							"type": "concourse-resource",
+							"user": "some-user",
+						},
+					},
+				),
+			)
 			httpPOSTSuccessHandler = ghttp.CombineHandlers(
 				ghttp.VerifyRequest("POST", MatchRegexp(".*/pipelines/"+inputSource.SpinnakerApplication+"/"+pipelineName+".*")),
 				ghttp.RespondWithJSONEncoded(
@@ -100,7 +123,7 @@ var _ = Describe("Out", func() {
 
 		Context("when no concourse params are defined", func() {
 			BeforeEach(func() {
-				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler)
+				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler, executionHandler)
 			})
 			It("returns the pipeline execution id", func() {
 				cmd := exec.Command(outPath, "")
@@ -114,6 +137,33 @@ var _ = Describe("Out", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(outResponse.Version.Ref).To(Equal(pipelineExecutionID))
 			})
+
+			Context("when the spinnaker ui url is configured", func() {
+				BeforeEach(func() {
+					inputSource.SpinnakerUIURL = "https://spinnaker.example.com/"
+				})
+
+				It("returns metadata describing the pipeline execution", func() {
+					cmd := exec.Command(outPath, "")
+					cmd.Stdin = bytes.NewBuffer(marshalledInput)
+					outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).ToNot(HaveOccurred())
+					<-outSess.Exited
+					Expect(outSess.ExitCode()).To(Equal(0))
+
+					err = json.Unmarshal(outSess.Out.Contents(), &outResponse)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(outResponse.Metadata).To(Equal([]concourse.MetadataPair{
+						{Name: "Application Name", Value: applicationName},
+						{Name: "Pipeline Name", Value: pipelineName},
+						{Name: "Execution Id", Value: pipelineExecutionID},
+						{Name: "Status", Value: "RUNNING"},
+						{Name: "Start time", Value: time.Unix(1543414041364/1000, 0).Format(time.UnixDate)},
+						{Name: "Triggered by", Value: "some-user"},
+						{Name: "Execution URL", Value: "https://spinnaker.example.com/#/applications/" + applicationName + "/executions/details/" + pipelineExecutionID},
+					}))
+				})
+			})
 		})
 
 		Context("when artifacts are defined", func() {
@@ -129,7 +179,7 @@ var _ = Describe("Out", func() {
 						},
 					),
 				)
-				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler)
+				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler, executionHandler)
 
 				dir, err := ioutil.TempDir("", "location_for_artifact")
 				Expect(err).ToNot(HaveOccurred())
@@ -172,7 +222,7 @@ var _ = Describe("Out", func() {
 						},
 					),
 				)
-				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler)
+				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler, executionHandler)
 
 				dir, err := ioutil.TempDir("", "location_for_params")
 				Expect(err).ToNot(HaveOccurred())
@@ -214,10 +264,10 @@ var _ = Describe("Out", func() {
 						},
 					),
 				)
-				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler)
+				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler, executionHandler)
 
 				inputParams = concourse.OutParams{
-					TriggerParams: map[string]string{
+					TriggerParams: map[string]interface{}{
 						"foo":    "bar",
 						"foobar": "$BAZ",
 					},
@@ -268,7 +318,7 @@ var _ = Describe("Out", func() {
 					)
 				})
 
-				It("print a '.' for every check and times out and exits with a non zero status and prints an error message", func() {
+				It("times out and exits with a non zero status and prints an error message", func() {
 					cmd := exec.Command(outPath, "")
 					cmd.Stdin = bytes.NewBuffer(marshalledInput)
 					outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
@@ -276,12 +326,114 @@ var _ = Describe("Out", func() {
 					Eventually(outSess.Exited).Should(BeClosed())
 					Expect(outSess.ExitCode()).To(Equal(1))
 
-					Expect(outSess.Err).To(gbytes.Say("\\.\\.\n"))
 					Expect(outSess.Err).To(gbytes.Say("error put step failed: "))
 					Expect(outSess.Err).To(gbytes.Say("timed out waiting for configured status\\(es\\)"))
 				})
 			})
 
+			Context("when the timeout is reached and on_timeout is cancel", func() {
+				BeforeEach(func() {
+					inputSource.StatusCheckTimeout = "500ms"
+					inputParams = concourse.OutParams{OnTimeout: "cancel"}
+
+					runningHandler := ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", MatchRegexp(".*/pipelines/"+pipelineExecutionID+".*")),
+						ghttp.RespondWithJSONEncoded(
+							200,
+							map[string]string{
+								"id":     pipelineExecutionID,
+								"status": "RUNNING",
+							},
+						),
+					)
+					spinnakerServer.AppendHandlers(
+						runningHandler,
+						runningHandler,
+						runningHandler,
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("PUT", "/pipelines/"+pipelineExecutionID+"/cancel"),
+							ghttp.RespondWith(202, nil),
+						),
+					)
+				})
+
+				It("cancels the pipeline execution with a reason identifying the build", func() {
+					cmd := exec.Command(outPath, "")
+					cmd.Env = []string{"BUILD_TEAM_NAME=main", "BUILD_PIPELINE_NAME=deploy", "BUILD_JOB_NAME=prod", "BUILD_NAME=42"}
+					cmd.Stdin = bytes.NewBuffer(marshalledInput)
+					outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).ToNot(HaveOccurred())
+					Eventually(outSess.Exited).Should(BeClosed())
+					Expect(outSess.ExitCode()).To(Equal(1))
+
+					Expect(outSess.Err).To(gbytes.Say("Cancelling pipeline execution " + pipelineExecutionID))
+					Expect(outSess.Err).To(gbytes.Say("timed out waiting for configured status\\(es\\)"))
+
+					requests := spinnakerServer.ReceivedRequests()
+					Expect(requests).To(HaveLen(7))
+					Expect(requests[6].URL.Query().Get("reason")).To(Equal("Concourse build main/deploy/prod #42 timed out"))
+				})
+			})
+
+			Context("when the build is aborted and on_abort is pause", func() {
+				BeforeEach(func() {
+					inputSource.StatusCheckTimeout = "1m"
+					inputSource.StatusCheckInterval = "1m"
+					inputParams = concourse.OutParams{OnAbort: "pause"}
+
+					spinnakerServer.AppendHandlers(
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", MatchRegexp(".*/pipelines/"+pipelineExecutionID+".*")),
+							ghttp.RespondWithJSONEncoded(
+								200,
+								map[string]string{
+									"id":     pipelineExecutionID,
+									"status": "RUNNING",
+								},
+							),
+						),
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("PUT", "/pipelines/"+pipelineExecutionID+"/pause"),
+							ghttp.RespondWith(202, nil),
+						),
+					)
+				})
+
+				It("pauses the pipeline execution", func() {
+					cmd := exec.Command(outPath, "")
+					cmd.Stdin = bytes.NewBuffer(marshalledInput)
+					outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).ToNot(HaveOccurred())
+					Eventually(spinnakerServer.ReceivedRequests).Should(HaveLen(4))
+
+					outSess.Terminate()
+					Eventually(outSess.Exited).Should(BeClosed())
+					Expect(outSess.ExitCode()).To(Equal(1))
+
+					Expect(outSess.Err).To(gbytes.Say("Pausing pipeline execution " + pipelineExecutionID))
+					Expect(outSess.Err).To(gbytes.Say("aborted \\(terminated\\) while waiting for configured status\\(es\\)"))
+					Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(5))
+				})
+			})
+
+			Context("when on_timeout is not a supported action", func() {
+				BeforeEach(func() {
+					inputParams = concourse.OutParams{OnTimeout: "explode"}
+				})
+
+				It("errors before triggering the pipeline", func() {
+					cmd := exec.Command(outPath, "")
+					cmd.Stdin = bytes.NewBuffer(marshalledInput)
+					outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).ToNot(HaveOccurred())
+					<-outSess.Exited
+					Expect(outSess.ExitCode()).To(Equal(1))
+
+					Expect(outSess.Err).To(gbytes.Say(`invalid on_timeout: "explode", must be one of: leave, cancel, pause`))
+					Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+				})
+			})
+
 			Context("when a status is specified, and an unexpected final status reached", func() {
 				BeforeEach(func() {
 					statusHandlers := []http.HandlerFunc{
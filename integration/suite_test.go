@@ -10,6 +10,7 @@ Unless required by applicable law or agreed to in writing, software distributed
 package integration_test
 
 import (
+	"encoding/pem"
 	"strings"
 	"testing"
 	"time"
@@ -71,6 +72,7 @@ JBf0Z15NGOY3w9KSxeiTPFyXU4/RmymDzyd/VKcnPBMKqTvbqT2G
 var (
 	outPath, checkPath, inPath string
 	spinnakerServer            *ghttp.Server
+	spinnakerTLSServer         *ghttp.Server
 )
 
 func TestIntegration(t *testing.T) {
@@ -103,8 +105,19 @@ var _ = SynchronizedAfterSuite(func() {
 
 var _ = BeforeEach(func() {
 	spinnakerServer = ghttp.NewServer()
+	spinnakerTLSServer = ghttp.NewTLSServer()
 })
 
 var _ = AfterEach(func() {
 	spinnakerServer.Close()
+	spinnakerTLSServer.Close()
 })
+
+// spinnakerTLSServerCA returns the PEM encoded certificate presented by spinnakerTLSServer,
+// which is self signed and therefore also its own CA.
+func spinnakerTLSServerCA() string {
+	return string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: spinnakerTLSServer.HTTPTestServer.Certificate().Raw,
+	}))
+}
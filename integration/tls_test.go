@@ -0,0 +1,146 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package integration_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+)
+
+var _ = Describe("TLS verification", func() {
+	var (
+		applicationName, pipelineName string
+		source                        concourse.Source
+		checkSess                     *gexec.Session
+	)
+
+	BeforeEach(func() {
+		applicationName = "bar"
+		pipelineName = "foo"
+		source = concourse.Source{
+			SpinnakerAPI:         spinnakerTLSServer.URL(),
+			SpinnakerApplication: applicationName,
+			SpinnakerPipeline:    pipelineName,
+			X509Cert:             serverCert,
+			X509Key:              serverKey,
+		}
+		spinnakerTLSServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName)),
+				ghttp.RespondWithJSONEncoded(
+					200,
+					map[string]interface{}{
+						"name": applicationName,
+					},
+				)),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelineConfigs")),
+				ghttp.RespondWithJSONEncoded(
+					200,
+					[]map[string]string{
+						{"id": "PC1", "name": pipelineName},
+					},
+				)),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/executions"),
+				ghttp.RespondWithJSONEncoded(
+					200,
+					[]map[string]interface{}{},
+				)),
+		)
+	})
+
+	JustBeforeEach(func() {
+		marshalledInput, err := json.Marshal(concourse.CheckRequest{Source: source})
+		Expect(err).ToNot(HaveOccurred())
+
+		cmd := exec.Command(checkPath)
+		cmd.Stdin = bytes.NewBuffer(marshalledInput)
+		checkSess, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).ToNot(HaveOccurred())
+		<-checkSess.Exited
+	})
+
+	Context("when no CA bundle is configured", func() {
+		It("rejects the self signed server certificate", func() {
+			Expect(checkSess.ExitCode()).To(Equal(1))
+			Expect(checkSess.Err).To(gbytes.Say("error check step failed: "))
+			Expect(checkSess.Err).To(gbytes.Say("certificate signed by unknown authority"))
+			Expect(spinnakerTLSServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when skip_tls_verify is enabled", func() {
+		BeforeEach(func() {
+			source.SkipTLSVerify = true
+		})
+
+		It("accepts the self signed server certificate", func() {
+			Expect(checkSess.ExitCode()).To(Equal(0))
+			Expect(spinnakerTLSServer.ReceivedRequests()).To(HaveLen(3))
+		})
+	})
+
+	Context("when the CA bundle contains the server certificate", func() {
+		BeforeEach(func() {
+			source.CACert = spinnakerTLSServerCA()
+		})
+
+		It("verifies the server certificate", func() {
+			Expect(checkSess.ExitCode()).To(Equal(0))
+			Expect(spinnakerTLSServer.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		Context("when the server name override matches the certificate", func() {
+			BeforeEach(func() {
+				source.ServerName = "example.com"
+			})
+
+			It("verifies the server certificate against the override", func() {
+				Expect(checkSess.ExitCode()).To(Equal(0))
+				Expect(spinnakerTLSServer.ReceivedRequests()).To(HaveLen(3))
+			})
+		})
+
+		Context("when the server name override does not match the certificate", func() {
+			BeforeEach(func() {
+				source.ServerName = "gate.spinnaker.invalid"
+			})
+
+			It("rejects the server certificate", func() {
+				Expect(checkSess.ExitCode()).To(Equal(1))
+				Expect(checkSess.Err).To(gbytes.Say("error check step failed: "))
+				Expect(checkSess.Err).To(gbytes.Say("gate.spinnaker.invalid"))
+				Expect(spinnakerTLSServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when the CA bundle is not valid PEM", func() {
+		BeforeEach(func() {
+			source.CACert = "not a certificate"
+		})
+
+		It("errors before contacting spinnaker", func() {
+			Expect(checkSess.ExitCode()).To(Equal(1))
+			Expect(checkSess.Err).To(gbytes.Say("spinnaker_ca_cert does not contain any valid PEM encoded certificates"))
+			Expect(spinnakerTLSServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})
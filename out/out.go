@@ -0,0 +1,368 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/check"
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	"github.com/hellofresh/spinnaker-resource/trigger"
+)
+
+const defaultPollingInterval = "30s"
+const defaultPollingTimeout = "31s"
+
+// What to do with the pipeline execution when the put times out or is aborted.
+const (
+	stopActionLeave  = "leave"
+	stopActionCancel = "cancel"
+	stopActionPause  = "pause"
+)
+
+// abortSignals are sent by Concourse when a build is aborted.
+var abortSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// Run executes the put step, triggering the configured pipeline with the sources
+// directory given as the first argument.
+func Run(stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	if len(args) < 2 {
+		return concourse.Fail(fmt.Sprintf("usage: %s <sources directory>", args[0]), errors.New("not enough arguments supplied"))
+	}
+
+	var request concourse.OutRequest
+	if err := concourse.ReadRequest(stdin, &request); err != nil {
+		return err
+	}
+
+	sourcesDir := args[1]
+
+	if err := validateStopAction("on_timeout", request.Params.OnTimeout); err != nil {
+		return concourse.Fail("put step failed", err)
+	}
+	if err := validateStopAction("on_abort", request.Params.OnAbort); err != nil {
+		return concourse.Fail("put step failed", err)
+	}
+	condition, err := newWaitCondition(request)
+	if err != nil {
+		return concourse.Fail("put step failed", err)
+	}
+
+	spinClient, err := spinnaker.NewClient(request.Source)
+	if err != nil {
+		return concourse.Fail("put step failed", err)
+	}
+	request.Source = spinClient.Source()
+
+	if request.Params.ReportStage != nil {
+		if err := reportStage(stdout, stderr, spinClient, sourcesDir, request); err != nil {
+			return concourse.Fail("put step failed", err)
+		}
+		return nil
+	}
+
+	if request.Params.DryRun {
+		if err := dryRun(stdout, stderr, spinClient, sourcesDir, request); err != nil {
+			return concourse.Fail("put step failed", err)
+		}
+		return nil
+	}
+
+	pipelineExecutionID, err := invokePipeline(stderr, spinClient, sourcesDir, request)
+	if err != nil {
+		return concourse.Fail("put step failed", err)
+	}
+	var pipelineExecution spinnaker.PipelineExecution
+	if condition != nil {
+		pipelineExecution, err = pollSpinnakerForStatus(stderr, spinClient, request, pipelineExecutionID, condition)
+		if err != nil {
+			return concourse.Fail("put step failed", err)
+		}
+	} else {
+		// The execution may still be queued, e.g. behind limitConcurrent, in which case the
+		// version has no start time. The pipeline was triggered, so that is not an error.
+		pipelineExecution, err = spinClient.GetPipelineExecution(pipelineExecutionID)
+		if err != nil {
+			concourse.Sayf(stderr, "Could not fetch pipeline execution %s for metadata: %s\n", pipelineExecutionID, err)
+			pipelineExecution = spinnaker.PipelineExecution{ID: pipelineExecutionID}
+		}
+	}
+	return writeSuccessfulResponse(stdout, stderr, request.Source, pipelineExecution)
+}
+
+func invokePipeline(stderr io.Writer, spinClient spinnaker.SpinClient, sourcesDir string, request concourse.OutRequest) (string, error) {
+	payload, err := buildTrigger(spinClient, sourcesDir, request)
+	if err != nil {
+		return "", err
+	}
+	if !request.Params.SkipTriggerParamsValidation {
+		err = validateTrigger(spinClient.PipelineConfig(), parameterProblems(spinClient.PipelineConfig(), payload.Parameters))
+		if err != nil {
+			return "", err
+		}
+	}
+	postBody, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	concourse.Sayf(stderr, "Executing pipeline: '%s/%s'\n", request.Source.SpinnakerApplication, request.Source.SpinnakerPipeline)
+
+	pipelineExecution, err := spinClient.InvokePipelineExecution(postBody)
+	if err != nil {
+		return "", err
+	}
+	return pipelineExecution.ID, nil
+}
+
+// buildTrigger builds the trigger payload from the put params and the parameter defaults of
+// the pipeline config.
+func buildTrigger(spinClient spinnaker.SpinClient, sourcesDir string, request concourse.OutRequest) (trigger.Payload, error) {
+	payload, err := trigger.NewBuilder(request.Params, sourcesDir).Build()
+	if err != nil {
+		return trigger.Payload{}, err
+	}
+	payload.Parameters = withParameterDefaults(spinClient.PipelineConfig(), payload.Parameters)
+	return payload, nil
+}
+
+// dryRun validates the trigger against the pipeline config and prints it instead of
+// triggering the pipeline.
+func dryRun(stdout, stderr io.Writer, spinClient spinnaker.SpinClient, sourcesDir string, request concourse.OutRequest) error {
+	config := spinClient.PipelineConfig()
+	payload, err := buildTrigger(spinClient, sourcesDir, request)
+	if err != nil {
+		return err
+	}
+
+	var artifacts []spinnaker.Artifact
+	if len(payload.Artifacts) > 0 {
+		bytes, err := json.Marshal(payload.Artifacts)
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(bytes, &artifacts); err != nil {
+			return fmt.Errorf("decoding artifacts: %s", err)
+		}
+	}
+	var problems []string
+	if !request.Params.SkipTriggerParamsValidation {
+		problems = parameterProblems(config, payload.Parameters)
+	}
+	problems = append(problems, artifactProblems(config, artifacts)...)
+	if err = validateTrigger(config, problems); err != nil {
+		return err
+	}
+
+	postBody, err := json.MarshalIndent(payload, "", "  ")
+	if err != nil {
+		return err
+	}
+	concourse.Sayf(stderr, "Dry run, not executing pipeline: '%s/%s'\n", request.Source.SpinnakerApplication, request.Source.SpinnakerPipeline)
+	concourse.Sayf(stderr, "POST %s\n%s\n", spinClient.InvokePipelineExecutionURL(), postBody)
+
+	// The output version is saved even with no_get, so emit an existing one rather than an empty ref.
+	versions, err := check.Versions(stderr, spinClient, concourse.Version{})
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("dry run has no version to emit, pipeline %s has no execution check would emit", request.Source.SpinnakerPipeline)
+	}
+
+	return concourse.WriteResponse(stdout, concourse.OutResponse{
+		Version: versions[0],
+		Metadata: []concourse.MetadataPair{
+			{Name: "Application Name", Value: request.Source.SpinnakerApplication},
+			{Name: "Pipeline Name", Value: request.Source.SpinnakerPipeline},
+			{Name: "Dry run", Value: "true"},
+		},
+	})
+}
+
+func validateStopAction(param, action string) error {
+	switch action {
+	case "", stopActionLeave, stopActionCancel, stopActionPause:
+		return nil
+	}
+	return fmt.Errorf("invalid %s: %q, must be one of: %s, %s, %s", param, action, stopActionLeave, stopActionCancel, stopActionPause)
+}
+
+// stopPipelineExecution cancels or pauses the execution according to action, leaving it running by default.
+func stopPipelineExecution(stderr io.Writer, spinClient spinnaker.SpinClient, action, pipelineExecutionID, reason string) error {
+	switch action {
+	case stopActionCancel:
+		concourse.Sayf(stderr, "Cancelling pipeline execution %s: %s\n", pipelineExecutionID, reason)
+		return spinClient.CancelPipelineExecution(pipelineExecutionID, reason)
+	case stopActionPause:
+		concourse.Sayf(stderr, "Pausing pipeline execution %s: %s\n", pipelineExecutionID, reason)
+		return spinClient.PausePipelineExecution(pipelineExecutionID)
+	}
+	return nil
+}
+
+// stopReason describes why the put stopped waiting, identifying the Concourse build.
+func stopReason(what string) string {
+	return fmt.Sprintf("Concourse build %s/%s/%s #%s %s",
+		os.Getenv("BUILD_TEAM_NAME"), os.Getenv("BUILD_PIPELINE_NAME"), os.Getenv("BUILD_JOB_NAME"), os.Getenv("BUILD_NAME"), what)
+}
+
+func parseDurationDefault(stringDuration, defaultDuration string) (time.Duration, error) {
+	if stringDuration == "" {
+		return time.ParseDuration(defaultDuration)
+	}
+	return time.ParseDuration(stringDuration)
+}
+
+func pollSpinnakerForStatus(stderr io.Writer, spinClient spinnaker.SpinClient, request concourse.OutRequest, pipelineExecutionID string, condition waitCondition) (spinnaker.PipelineExecution, error) {
+
+	interval, err := parseDurationDefault(request.Source.StatusCheckInterval, defaultPollingInterval)
+	if err != nil {
+		return spinnaker.PipelineExecution{}, err
+	}
+	timeout, err := parseDurationDefault(request.Source.StatusCheckTimeout, defaultPollingTimeout)
+	if err != nil {
+		return spinnaker.PipelineExecution{}, err
+	}
+
+	concourse.Sayf(stderr, "Poll Interval: %v, Timeout: %v\n", interval, timeout)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, abortSignals...)
+	defer signal.Stop(signals)
+
+	progress := newProgressReporter(stderr)
+	defer progress.summary()
+
+	statusReached, err := pollForStatus(progress, spinClient, pipelineExecutionID, condition)
+	if err != nil {
+		return progress.last, err
+	}
+	if statusReached {
+		return progress.last, nil
+	}
+
+	pollTicker := time.NewTicker(interval)
+	defer pollTicker.Stop()
+	timeoutTimer := time.NewTimer(timeout)
+	defer timeoutTimer.Stop()
+
+	for {
+		select {
+
+		case <-pollTicker.C:
+			statusReached, err := pollForStatus(progress, spinClient, pipelineExecutionID, condition)
+			if err != nil {
+				return progress.last, err
+			}
+			if statusReached {
+				return progress.last, nil
+			}
+		case <-timeoutTimer.C:
+			err := fmt.Errorf("timed out waiting for %s", condition)
+			if stopErr := stopPipelineExecution(stderr, spinClient, request.Params.OnTimeout, pipelineExecutionID, stopReason("timed out")); stopErr != nil {
+				return progress.last, fmt.Errorf("%s, and failed to %s the pipeline execution: %s", err, request.Params.OnTimeout, stopErr)
+			}
+			return progress.last, err
+		case sig := <-signals:
+			err := fmt.Errorf("aborted (%s) while waiting for %s", sig, condition)
+			if stopErr := stopPipelineExecution(stderr, spinClient, request.Params.OnAbort, pipelineExecutionID, stopReason("was aborted")); stopErr != nil {
+				return progress.last, fmt.Errorf("%s, and failed to %s the pipeline execution: %s", err, request.Params.OnAbort, stopErr)
+			}
+			return progress.last, err
+		}
+	}
+
+}
+
+func pollForStatus(progress *progressReporter, spinClient spinnaker.SpinClient, pipelineExecutionID string, condition waitCondition) (bool, error) {
+	pipelineExecution, err := spinClient.GetPipelineExecution(pipelineExecutionID)
+	if err != nil {
+		return false, err
+	}
+
+	progress.update(pipelineExecution)
+	return condition.reached(pipelineExecution)
+}
+
+func writeSuccessfulResponse(stdout, stderr io.Writer, source concourse.Source, pipelineExecution spinnaker.PipelineExecution) error {
+	output := concourse.OutResponse{}
+	output.Version = pipelineExecution.Version()
+	output.Metadata = executionMetadata(source, pipelineExecution)
+
+	concourse.Sayf(stderr, "Pipeline executed successfully")
+
+	return concourse.WriteResponse(stdout, output)
+}
+
+// executionMetadata describes the execution in the Concourse UI, like the metadata of the get step.
+func executionMetadata(source concourse.Source, pipelineExecution spinnaker.PipelineExecution) []concourse.MetadataPair {
+	metadata := []concourse.MetadataPair{
+		{Name: "Application Name", Value: source.SpinnakerApplication},
+		{Name: "Pipeline Name", Value: source.SpinnakerPipeline},
+		{Name: "Execution Id", Value: pipelineExecution.ID},
+	}
+	if pipelineExecution.Status != "" {
+		metadata = append(metadata, concourse.MetadataPair{Name: "Status", Value: pipelineExecution.Status})
+	}
+	if pipelineExecution.StartTime > 0 {
+		metadata = append(metadata, concourse.MetadataPair{
+			Name:  "Start time",
+			Value: time.Unix(pipelineExecution.StartTime/1000, 0).Format(time.UnixDate),
+		})
+	}
+	if pipelineExecution.EndTime > 0 {
+		metadata = append(metadata, concourse.MetadataPair{
+			Name:  "End time",
+			Value: time.Unix(pipelineExecution.EndTime/1000, 0).Format(time.UnixDate),
+		})
+	}
+	if pipelineExecution.StartTime > 0 && pipelineExecution.EndTime > 0 {
+		metadata = append(metadata, concourse.MetadataPair{
+			Name:  "Duration",
+			Value: (time.Duration(pipelineExecution.EndTime-pipelineExecution.StartTime) * time.Millisecond).Round(time.Second).String(),
+		})
+	}
+	user := pipelineExecution.Trigger.User
+	if user == "" {
+		user = pipelineExecution.Authentication.User
+	}
+	if user != "" {
+		metadata = append(metadata, concourse.MetadataPair{Name: "Triggered by", Value: user})
+	}
+	if source.SpinnakerUIURL != "" {
+		metadata = append(metadata, concourse.MetadataPair{
+			Name:  "Execution URL",
+			Value: fmt.Sprintf("%s/#/applications/%s/executions/details/%s", strings.TrimSuffix(source.SpinnakerUIURL, "/"), source.SpinnakerApplication, pipelineExecution.ID),
+		})
+	}
+	return metadata
+}
+
+func checkStatus(status string, statuses []string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, currStatus := range statuses {
+		if status == currStatus {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,973 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/hellofresh/spinnaker-resource/check"
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/out"
+)
+
+// checkVersion returns the version check emits for execution, the only execution of the pipeline.
+func checkVersion(execution map[string]interface{}) concourse.Version {
+	gateServer := ghttp.NewServer()
+	defer gateServer.Close()
+	gateServer.AppendHandlers(
+		ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+		ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}),
+		ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{execution}),
+	)
+	request, err := json.Marshal(concourse.CheckRequest{
+		Source: concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: "bar",
+			SpinnakerPipeline:    "foo",
+			Auth:                 concourse.Auth{Type: "basic", Username: "user", Password: "password"},
+		},
+	})
+	Expect(err).ToNot(HaveOccurred())
+
+	stdout := &bytes.Buffer{}
+	Expect(check.Run(bytes.NewBuffer(request), stdout, &bytes.Buffer{}, []string{"check"})).To(Succeed())
+	var versions []concourse.Version
+	Expect(json.Unmarshal(stdout.Bytes(), &versions)).To(Succeed())
+	Expect(versions).To(HaveLen(1))
+	return versions[0]
+}
+
+var _ = Describe("Run", func() {
+	var (
+		gateServer     *ghttp.Server
+		request        concourse.OutRequest
+		stdout, stderr *bytes.Buffer
+		runErr         error
+	)
+
+	BeforeEach(func() {
+		gateServer = ghttp.NewServer()
+		gateServer.AppendHandlers(
+			ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "bar"}),
+			ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "foo"}}),
+		)
+		request = concourse.OutRequest{
+			Source: concourse.Source{
+				SpinnakerAPI:         gateServer.URL(),
+				SpinnakerApplication: "bar",
+				SpinnakerPipeline:    "foo",
+				Auth: concourse.Auth{
+					Type:     "basic",
+					Username: "user",
+					Password: "password",
+				},
+			},
+		}
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		stdin, err := json.Marshal(request)
+		Expect(err).ToNot(HaveOccurred())
+		runErr = out.Run(bytes.NewBuffer(stdin), stdout, stderr, []string{"out", ""})
+	})
+
+	Context("when spinnaker accepts the pipeline execution", func() {
+		var execution map[string]interface{}
+
+		BeforeEach(func() {
+			execution = map[string]interface{}{"id": "EX1", "pipelineConfigId": "PC1", "status": "RUNNING", "startTime": 1000}
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, execution),
+				),
+			)
+		})
+
+		It("writes a single response with the execution id", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			var response concourse.OutResponse
+			decoder := json.NewDecoder(stdout)
+			Expect(decoder.Decode(&response)).To(Succeed())
+			Expect(response.Version.Ref).To(Equal("EX1"))
+			Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Status", Value: "RUNNING"}))
+			Expect(decoder.More()).To(BeFalse())
+		})
+
+		It("emits the version check emits for the execution", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			var response concourse.OutResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version).To(Equal(concourse.Version{Ref: "EX1", StartTime: "1000"}))
+			Expect(response.Version).To(Equal(checkVersion(execution)))
+		})
+
+		Context("when the execution has not started yet", func() {
+			BeforeEach(func() {
+				request.Params.OnTimeout = "cancel"
+				gateServer.SetHandler(3, ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "NOT_STARTED"}))
+			})
+
+			It("succeeds with a version without start time, without waiting for it", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+
+				var response concourse.OutResponse
+				Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+				Expect(response.Version).To(Equal(concourse.Version{Ref: "EX1"}))
+				Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Status", Value: "NOT_STARTED"}))
+			})
+		})
+
+		Context("when the execution cannot be fetched for metadata", func() {
+			BeforeEach(func() {
+				gateServer.SetHandler(3, ghttp.RespondWith(500, "boom"))
+			})
+
+			It("still succeeds with the metadata it has", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stderr.String()).To(ContainSubstring("Could not fetch pipeline execution EX1 for metadata"))
+
+				var response concourse.OutResponse
+				Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+				Expect(response.Version).To(Equal(concourse.Version{Ref: "EX1"}))
+				Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Execution Id", Value: "EX1"}))
+			})
+		})
+	})
+
+	Context("when the pipeline is triggered by id", func() {
+		BeforeEach(func() {
+			request.Source.SpinnakerPipeline = ""
+			request.Source.SpinnakerPipelineID = "PC1"
+			gateServer.SetHandler(1, ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "foo"}}))
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/PC1"),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+				),
+			)
+		})
+
+		It("triggers it through the endpoint that starts the execution before responding", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			var response concourse.OutResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version.Ref).To(Equal("EX1"))
+		})
+	})
+
+	Context("when trigger params are not strings", func() {
+		var paramsDir string
+
+		BeforeEach(func() {
+			var err error
+			paramsDir, err = ioutil.TempDir("", "params")
+			Expect(err).ToNot(HaveOccurred())
+
+			os.Setenv("OUT_TEST_REGION", "eu-west-1")
+			request.Params.TriggerParams = map[string]interface{}{
+				"replicas": 3,
+				"canary":   true,
+				"target":   map[string]interface{}{"region": "$OUT_TEST_REGION", "zones": []interface{}{"a", "b"}},
+				"version":  "from-params",
+			}
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("OUT_TEST_REGION")
+			os.RemoveAll(paramsDir)
+		})
+
+		expectTrigger := func(body string) {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+					ghttp.VerifyJSON(body),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+			)
+		}
+
+		Context("when they are given inline", func() {
+			BeforeEach(func() {
+				request.Params.SkipTriggerParamsValidation = true
+				expectTrigger(`{"type": "concourse-resource", "parameters": {
+					"replicas": 3, "canary": true, "version": "from-params",
+					"target": {"region": "eu-west-1", "zones": ["a", "b"]}
+				}}`)
+			})
+
+			It("preserves their types in the trigger", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when they are read from a JSON file", func() {
+			BeforeEach(func() {
+				path := filepath.Join(paramsDir, "params.json")
+				Expect(ioutil.WriteFile(path, []byte(`{"version": "from-file", "weights": [0.5, 0.5], "limits": {"cpu": 2}}`), 0644)).To(Succeed())
+				request.Params.TriggerParamsJSONFilePath = path
+				request.Params.SkipTriggerParamsValidation = true
+				expectTrigger(`{"type": "concourse-resource", "parameters": {
+					"replicas": 3, "canary": true, "version": "from-file",
+					"target": {"region": "eu-west-1", "zones": ["a", "b"]},
+					"weights": [0.5, 0.5], "limits": {"cpu": 2}
+				}}`)
+			})
+
+			It("merges them over the inline params, preserving their types", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when they are read from a YAML file", func() {
+			BeforeEach(func() {
+				path := filepath.Join(paramsDir, "params.yml")
+				Expect(ioutil.WriteFile(path, []byte("version: from-yaml\nlimits:\n  cpu: 2\n  burst: false\n"), 0644)).To(Succeed())
+				request.Params.TriggerParamsJSONFilePath = path
+				request.Params.SkipTriggerParamsValidation = true
+				expectTrigger(`{"type": "concourse-resource", "parameters": {
+					"replicas": 3, "canary": true, "version": "from-yaml",
+					"target": {"region": "eu-west-1", "zones": ["a", "b"]},
+					"limits": {"cpu": 2, "burst": false}
+				}}`)
+			})
+
+			It("parses them as YAML", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the file can't be parsed", func() {
+			BeforeEach(func() {
+				path := filepath.Join(paramsDir, "params.json")
+				Expect(ioutil.WriteFile(path, []byte(`version: 1`), 0644)).To(Succeed())
+				request.Params.TriggerParamsJSONFilePath = path
+			})
+
+			It("returns an error naming the file", func() {
+				Expect(runErr).To(MatchError(HavePrefix("put step failed: parsing " + filepath.Join(paramsDir, "params.json") + ": ")))
+			})
+		})
+	})
+
+	Context("when run by a Concourse build", func() {
+		buildMetadata := map[string]string{
+			"ATC_EXTERNAL_URL":    "https://ci.example.com/",
+			"BUILD_TEAM_NAME":     "main",
+			"BUILD_PIPELINE_NAME": "deploy app",
+			"BUILD_JOB_NAME":      "prod",
+			"BUILD_NAME":          "42",
+			"BUILD_CREATED_BY":    "alice",
+		}
+
+		BeforeEach(func() {
+			for name, value := range buildMetadata {
+				os.Setenv(name, value)
+			}
+		})
+
+		AfterEach(func() {
+			for name := range buildMetadata {
+				os.Unsetenv(name)
+			}
+			os.Unsetenv("BUILD_PIPELINE_INSTANCE_VARS")
+		})
+
+		expectTrigger := func(body string) {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+					ghttp.VerifyJSON(body),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+			)
+		}
+
+		Context("when the build has a build number", func() {
+			BeforeEach(func() {
+				expectTrigger(`{
+					"type": "concourse-resource",
+					"user": "alice",
+					"team": "main",
+					"pipeline": "deploy app",
+					"job": "prod",
+					"buildNumber": 42,
+					"buildInfo": {"name": "42", "url": "https://ci.example.com/teams/main/pipelines/deploy%20app/jobs/prod/builds/42"}
+				}`)
+			})
+
+			It("identifies the build in the trigger", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the build is a rerun of an instanced pipeline", func() {
+			BeforeEach(func() {
+				os.Setenv("BUILD_NAME", "42.1")
+				os.Setenv("BUILD_PIPELINE_INSTANCE_VARS", `{"env":"prod"}`)
+				expectTrigger(`{
+					"type": "concourse-resource",
+					"user": "alice",
+					"team": "main",
+					"pipeline": "deploy app",
+					"job": "prod",
+					"buildInfo": {"name": "42.1", "url": "https://ci.example.com/teams/main/pipelines/deploy%20app/jobs/prod/builds/42.1?vars=%7B%22env%22%3A%22prod%22%7D"}
+				}`)
+			})
+
+			It("links to the build without a build number", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+	})
+
+	Context("when trigger params and artifacts are templates", func() {
+		var sourcesDir string
+
+		BeforeEach(func() {
+			var err error
+			sourcesDir, err = ioutil.TempDir("", "sources")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, "version"), []byte("1.2.3\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, "artifacts.json"), []byte(
+				`[{"type": "docker/image", "name": "org/app", "reference": {{ printf "org/app:%s" (file "`+filepath.Join(sourcesDir, "version")+`" | trim) | json }}}]`,
+			), 0644)).To(Succeed())
+
+			os.Setenv("BUILD_JOB_NAME", "deploy")
+			os.Setenv("BUILD_NAME", "42")
+			os.Setenv("OUT_TEST_SHA", "abc123")
+
+			request.Params.SkipTriggerParamsValidation = true
+			request.Params.Artifacts = filepath.Join(sourcesDir, "artifacts.json")
+			request.Params.TriggerParams = map[string]interface{}{
+				"version": `{{ file "` + filepath.Join(sourcesDir, "version") + `" | trim }}`,
+				"build":   "{{ .BUILD_JOB_NAME }}/{{ .BUILD_NAME }}",
+				"git":     map[string]interface{}{"sha": `{{ env "OUT_TEST_SHA" }}`, "branch": `{{ env "OUT_TEST_BRANCH" }}`},
+				"plain":   "$OUT_TEST_SHA",
+			}
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("BUILD_JOB_NAME")
+			os.Unsetenv("BUILD_NAME")
+			os.Unsetenv("OUT_TEST_SHA")
+			os.RemoveAll(sourcesDir)
+		})
+
+		Context("when every variable is set", func() {
+			BeforeEach(func() {
+				gateServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+						ghttp.VerifyJSON(`{
+							"type": "concourse-resource",
+							"job": "deploy",
+							"buildNumber": 42,
+							"buildInfo": {"name": "42"},
+							"parameters": {"version": "1.2.3", "build": "deploy/42", "git": {"sha": "abc123", "branch": ""}, "plain": "abc123"},
+							"artifacts": [{"type": "docker/image", "name": "org/app", "reference": "org/app:1.2.3"}]
+						}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+					),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+				)
+			})
+
+			It("renders them before triggering the pipeline", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when strict_templates is set and an environment variable is missing", func() {
+			BeforeEach(func() {
+				request.Params.StrictTemplates = true
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError(ContainSubstring("rendering template trigger_params.git.branch: ")))
+				Expect(runErr).To(MatchError(ContainSubstring("environment variable OUT_TEST_BRANCH is not set")))
+			})
+		})
+
+		Context("when strict_templates is set and build metadata is missing", func() {
+			BeforeEach(func() {
+				request.Params.StrictTemplates = true
+				request.Params.TriggerParams = map[string]interface{}{"build": "{{ .BUILD_TEAM_NAME }}"}
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError(ContainSubstring(`rendering template trigger_params.build: `)))
+				Expect(runErr).To(MatchError(ContainSubstring(`map has no entry for key "BUILD_TEAM_NAME"`)))
+			})
+		})
+	})
+
+	Context("when artifacts are declared", func() {
+		var sourcesDir string
+
+		BeforeEach(func() {
+			var err error
+			sourcesDir, err = ioutil.TempDir("", "sources")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(os.MkdirAll(filepath.Join(sourcesDir, "image"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, "image", "repository"), []byte("registry.example.com/org/app\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, "image", "digest"), []byte("sha256:abc\n"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, "manifest.yml"), []byte("kind: Pod"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, "artifacts.json"), []byte(`[{"type": "gcs/object", "reference": "gs://bucket/file"}]`), 0644)).To(Succeed())
+
+			request.Params.Artifacts = filepath.Join(sourcesDir, "artifacts.json")
+			request.Params.ArtifactSpecs = []concourse.ArtifactSpec{
+				{Type: "docker/image", ImageDir: filepath.Join(sourcesDir, "image")},
+				{Type: "embedded/base64", File: filepath.Join(sourcesDir, "manifest.yml")},
+				{Type: "github/file", Name: "deploy/values.yml", Reference: "https://api.github.com/repos/org/app/contents/deploy/values.yml", Version: "main"},
+				{Type: "http/file", Reference: "https://example.com/values.yml"},
+				{Type: "s3/object", Reference: "s3://bucket/values.yml"},
+				{Type: "helm/chart", Name: "app", Version: "1.0.0", ArtifactAccount: "charts"},
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(sourcesDir)
+		})
+
+		Context("when they are valid", func() {
+			BeforeEach(func() {
+				gateServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+						ghttp.VerifyJSON(`{"type": "concourse-resource", "artifacts": [
+							{"type": "gcs/object", "reference": "gs://bucket/file"},
+							{"type": "docker/image", "name": "registry.example.com/org/app", "version": "sha256:abc", "reference": "registry.example.com/org/app@sha256:abc"},
+							{"type": "embedded/base64", "name": "manifest.yml", "reference": "a2luZDogUG9k"},
+							{"type": "github/file", "name": "deploy/values.yml", "version": "main", "reference": "https://api.github.com/repos/org/app/contents/deploy/values.yml"},
+							{"type": "http/file", "name": "https://example.com/values.yml", "reference": "https://example.com/values.yml"},
+							{"type": "s3/object", "name": "s3://bucket/values.yml", "reference": "s3://bucket/values.yml"},
+							{"type": "helm/chart", "name": "app", "version": "1.0.0", "artifactAccount": "charts"}
+						]}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+					),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+				)
+			})
+
+			It("triggers the pipeline with the built artifacts after those of artifacts_json_file", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when an artifact is missing a required field", func() {
+			BeforeEach(func() {
+				request.Params.ArtifactSpecs[5].Version = ""
+			})
+
+			It("errors without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("put step failed: artifacts[5] (helm/chart): requires name, version and artifact_account"))
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when an artifact has an unsupported type", func() {
+			BeforeEach(func() {
+				request.Params.ArtifactSpecs = []concourse.ArtifactSpec{{Type: "git/repo"}}
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError(HavePrefix(`put step failed: artifacts[0] (git/repo): unsupported artifact type "git/repo"`)))
+			})
+		})
+	})
+
+	Context("when the pipeline declares no parameters", func() {
+		BeforeEach(func() {
+			request.Params.TriggerParams = map[string]interface{}{"build_id": "7"}
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+					ghttp.VerifyJSON(`{"type": "concourse-resource", "parameters": {"build_id": "7"}}`),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+			)
+		})
+
+		It("triggers the pipeline with any trigger params", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when trigger params are integers beyond the precision of a float64", func() {
+		BeforeEach(func() {
+			request.Params.TriggerParams = map[string]interface{}{"commit_id": int64(1234567890123456789)}
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+					func(_ http.ResponseWriter, req *http.Request) {
+						body, err := ioutil.ReadAll(req.Body)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(string(body)).To(ContainSubstring(`"commit_id":1234567890123456789`))
+					},
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+			)
+		})
+
+		It("posts them unchanged", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+		})
+	})
+
+	Context("when the pipeline declares parameters", func() {
+		BeforeEach(func() {
+			gateServer.SetHandler(1, ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{
+				"id":   "PC1",
+				"name": "foo",
+				"parameterConfig": []map[string]interface{}{
+					{"name": "version", "required": true},
+					{"name": "region", "default": "eu-west-1", "hasOptions": true, "options": []map[string]string{{"value": "eu-west-1"}, {"value": "us-east-1"}}},
+				},
+			}}))
+		})
+
+		Context("when the trigger params match", func() {
+			BeforeEach(func() {
+				request.Params.TriggerParams = map[string]interface{}{"version": "1.0"}
+				gateServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+						ghttp.VerifyJSON(`{"type": "concourse-resource", "parameters": {"version": "1.0", "region": "eu-west-1"}}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+					),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+				)
+			})
+
+			It("triggers the pipeline with the declared defaults", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the trigger params do not match", func() {
+			BeforeEach(func() {
+				request.Params.TriggerParams = map[string]interface{}{"region": "ap-south-1", "replicas": "3"}
+			})
+
+			It("errors without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("put step failed: trigger does not match the config of pipeline foo: " +
+					"missing required parameter version; " +
+					"parameter region is \"ap-south-1\", must be one of: eu-west-1, us-east-1; " +
+					"unknown parameter replicas"))
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+			})
+
+			Context("when skip_trigger_params_validation is set", func() {
+				BeforeEach(func() {
+					request.Params.SkipTriggerParamsValidation = true
+					gateServer.AppendHandlers(
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+							ghttp.VerifyJSON(`{"type": "concourse-resource", "parameters": {"region": "ap-south-1", "replicas": "3"}}`),
+							ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+						),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": "RUNNING", "startTime": 1000}),
+					)
+				})
+
+				It("triggers the pipeline with the params as given", func() {
+					Expect(runErr).ToNot(HaveOccurred())
+				})
+			})
+		})
+	})
+
+	Context("when dry_run is set", func() {
+		var artifactsFile string
+
+		BeforeEach(func() {
+			gateServer.SetHandler(1, ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{
+				"id":   "PC1",
+				"name": "foo",
+				"parameterConfig": []map[string]interface{}{
+					{"name": "version", "required": true},
+					{"name": "region", "required": true, "default": "eu-west-1", "hasOptions": true, "options": []map[string]string{{"value": "eu-west-1"}, {"value": "us-east-1"}}},
+				},
+				"expectedArtifacts": []map[string]interface{}{
+					{"id": "A1", "displayName": "app-image", "matchArtifact": map[string]string{"type": "docker/image", "name": "org/.*"}},
+					{"id": "A2", "displayName": "optional", "matchArtifact": map[string]string{"type": "http/file"}, "useDefaultArtifact": true},
+				},
+			}}))
+
+			file, err := ioutil.TempFile("", "artifacts")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = file.WriteString(`[{"type": "docker/image", "name": "org/app", "reference": "org/app:1.0"}]`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			artifactsFile = file.Name()
+
+			request.Params.DryRun = true
+			request.Params.Artifacts = artifactsFile
+			request.Params.TriggerParams = map[string]interface{}{"version": "1.0"}
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=25"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+						{"id": "EX2", "pipelineConfigId": "PC1", "status": "RUNNING", "startTime": 2000},
+						{"id": "EX1", "pipelineConfigId": "PC1", "status": "SUCCEEDED", "startTime": 1000},
+					}),
+				),
+			)
+		})
+
+		AfterEach(func() {
+			os.Remove(artifactsFile)
+		})
+
+		It("prints the trigger without executing the pipeline", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+			Expect(stderr.String()).To(ContainSubstring("POST " + gateServer.URL() + "/pipelines/bar/foo\n"))
+			Expect(stderr.String()).To(ContainSubstring(`"version": "1.0"`))
+			Expect(stderr.String()).To(ContainSubstring(`"reference": "org/app:1.0"`))
+
+			var response concourse.OutResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version).To(Equal(concourse.Version{Ref: "EX2", StartTime: "2000"}))
+			Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Dry run", Value: "true"}))
+		})
+
+		Context("when statuses are configured", func() {
+			BeforeEach(func() {
+				request.Source.Statuses = []string{"SUCCEEDED"}
+			})
+
+			It("emits the latest version check would emit", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				var response concourse.OutResponse
+				Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+				Expect(response.Version).To(Equal(concourse.Version{Ref: "EX1", StartTime: "1000"}))
+			})
+		})
+
+		Context("when the pipeline has no execution yet", func() {
+			BeforeEach(func() {
+				gateServer.SetHandler(2, ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{}))
+			})
+
+			It("returns an error instead of emitting an empty version", func() {
+				Expect(runErr).To(MatchError("put step failed: dry run has no version to emit, pipeline foo has no execution check would emit"))
+				Expect(stdout.Len()).To(BeZero())
+			})
+		})
+
+		Context("when the trigger does not match the pipeline config", func() {
+			BeforeEach(func() {
+				request.Params.TriggerParams = map[string]interface{}{"region": "ap-south-1", "replicas": "3"}
+				request.Params.Artifacts = ""
+			})
+
+			It("reports every mismatch", func() {
+				Expect(runErr).To(MatchError("put step failed: trigger does not match the config of pipeline foo: " +
+					"missing required parameter version; " +
+					"parameter region is \"ap-south-1\", must be one of: eu-west-1, us-east-1; " +
+					"unknown parameter replicas; " +
+					"no artifact matches expected artifact app-image"))
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+				Expect(stdout.Len()).To(BeZero())
+			})
+		})
+	})
+
+	Context("when the execution does not reach the configured status", func() {
+		BeforeEach(func() {
+			request.Source.Statuses = []string{"SUCCEEDED"}
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "TERMINAL"}),
+				),
+			)
+		})
+
+		It("returns a put step error without writing a response", func() {
+			Expect(runErr).To(MatchError("put step failed: Pipeline execution reached a final state: TERMINAL"))
+			Expect(stdout.Len()).To(BeZero())
+		})
+	})
+
+	Context("when waiting for a stage", func() {
+		var stages []map[string]interface{}
+
+		execution := func(status string, stages []map[string]interface{}) http.HandlerFunc {
+			return ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": status, "stages": stages}),
+			)
+		}
+
+		BeforeEach(func() {
+			request.Source.StatusCheckInterval = "10ms"
+			request.Params.WaitForStage = &concourse.StageCondition{Name: "Deploy to prod"}
+			stages = []map[string]interface{}{
+				{"refId": "1", "name": "Bake", "status": "SUCCEEDED"},
+				{"refId": "2", "name": "Deploy to prod", "status": "RUNNING"},
+				{"refId": "3", "name": "Canary", "status": "NOT_STARTED"},
+			}
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				execution("RUNNING", stages),
+			)
+		})
+
+		Context("when the stage reaches the desired status while the pipeline is running", func() {
+			BeforeEach(func() {
+				stages[1]["status"] = "SUCCEEDED"
+				gateServer.AppendHandlers(execution("RUNNING", stages))
+			})
+
+			It("returns without waiting for the pipeline", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(5))
+			})
+		})
+
+		Context("when the stage is selected by refId and fails", func() {
+			BeforeEach(func() {
+				request.Params.WaitForStage = &concourse.StageCondition{RefID: "2", Statuses: []string{"SUCCEEDED"}}
+				stages[1]["status"] = "TERMINAL"
+				gateServer.AppendHandlers(execution("TERMINAL", stages))
+			})
+
+			It("returns an error naming the stage", func() {
+				Expect(runErr).To(MatchError(`put step failed: Stage with refId "2" reached a final state: TERMINAL`))
+			})
+		})
+
+		Context("when the pipeline finishes before the stage reaches the desired status", func() {
+			BeforeEach(func() {
+				request.Params.WaitForStage = &concourse.StageCondition{RefID: "4"}
+				gateServer.AppendHandlers(execution("SUCCEEDED", stages))
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError(`put step failed: Pipeline execution reached a final state: SUCCEEDED before stage with refId "4" reached status(es) SUCCEEDED`))
+			})
+		})
+
+		Context("when neither refId nor name is given", func() {
+			BeforeEach(func() {
+				request.Params.WaitForStage = &concourse.StageCondition{Statuses: []string{"SUCCEEDED"}}
+			})
+
+			It("errors before triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("put step failed: wait_for_stage requires ref_id or name"))
+				Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when polling a pipeline with stages", func() {
+		BeforeEach(func() {
+			request.Source.Statuses = []string{"SUCCEEDED"}
+			request.Source.StatusCheckInterval = "10ms"
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+					"id":     "EX1",
+					"status": "RUNNING",
+					"stages": []map[string]interface{}{
+						{"id": "S1", "name": "Bake", "type": "bake", "status": "SUCCEEDED", "startTime": 1000, "endTime": 61000},
+						{"id": "S2", "name": "Deploy to prod", "type": "deployManifest", "status": "NOT_STARTED"},
+					},
+				}),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+					"id":     "EX1",
+					"status": "RUNNING",
+					"stages": []map[string]interface{}{
+						{"id": "S1", "name": "Bake", "type": "bake", "status": "SUCCEEDED", "startTime": 1000, "endTime": 61000},
+						{"id": "S2", "name": "Deploy to prod", "type": "deployManifest", "status": "NOT_STARTED"},
+					},
+				}),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+					"id":     "EX1",
+					"status": "TERMINAL",
+					"stages": []map[string]interface{}{
+						{"id": "S1", "name": "Bake", "type": "bake", "status": "SUCCEEDED", "startTime": 1000, "endTime": 61000},
+						{
+							"id": "S2", "name": "Deploy to prod", "type": "deployManifest", "status": "TERMINAL", "startTime": 61000, "endTime": 66000,
+							"context": map[string]interface{}{
+								"exception": map[string]interface{}{
+									"details": map[string]interface{}{"errors": []string{"namespace prod not found"}},
+								},
+							},
+						},
+					},
+				}),
+			)
+		})
+
+		It("prints each stage transition once, with failure messages", func() {
+			Expect(runErr).To(MatchError("put step failed: Pipeline execution reached a final state: TERMINAL"))
+			Expect(stderr.String()).To(ContainSubstring("[SUCCEEDED] Bake (bake) in 1m0s\n[NOT_STARTED] Deploy to prod (deployManifest)\n[TERMINAL] Deploy to prod (deployManifest) in 5s: namespace prod not found\n"))
+		})
+
+		It("prints a summary of all stages", func() {
+			Expect(stderr.String()).To(ContainSubstring("Pipeline execution EX1: TERMINAL\n"))
+			Expect(stderr.String()).To(MatchRegexp(`STAGE\s+TYPE\s+STATUS\s+DURATION\n`))
+			Expect(stderr.String()).To(MatchRegexp(`Bake\s+bake\s+SUCCEEDED\s+1m0s\n`))
+			Expect(stderr.String()).To(MatchRegexp(`Deploy to prod\s+deployManifest\s+TERMINAL\s+5s\n`))
+		})
+	})
+
+	Context("when report_stage is set", func() {
+		var (
+			getDir    string
+			execution map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			var err error
+			getDir, err = ioutil.TempDir("", "get")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(getDir, "version"), []byte("EX1"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(getDir, "stage_id"), []byte("STAGE1"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(getDir, "properties.yml"), []byte("image: org/app:1.0\nreplicas: 3\n"), 0644)).To(Succeed())
+
+			os.Setenv("ATC_EXTERNAL_URL", "https://ci.example.com")
+			os.Setenv("BUILD_TEAM_NAME", "main")
+			os.Setenv("BUILD_PIPELINE_NAME", "app")
+			os.Setenv("BUILD_JOB_NAME", "build")
+			os.Setenv("BUILD_NAME", "7")
+
+			request.Params.ReportStage = &concourse.ReportStage{Dir: getDir, Status: "failed"}
+			execution = map[string]interface{}{"id": "EX1", "pipelineConfigId": "PC1", "status": "RUNNING", "startTime": 1000}
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, execution),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", "/pipelines/EX1/stages/STAGE1"),
+					ghttp.VerifyJSON(`{
+						"concourseReport": {
+							"buildInfo": {
+								"job": "build",
+								"name": "7",
+								"number": 7,
+								"url": "https://ci.example.com/teams/main/pipelines/app/jobs/build/builds/7",
+								"result": "FAILED",
+								"building": false
+							}
+						}
+					}`),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(getDir)
+			for _, name := range []string{"ATC_EXTERNAL_URL", "BUILD_TEAM_NAME", "BUILD_PIPELINE_NAME", "BUILD_JOB_NAME", "BUILD_NAME"} {
+				os.Unsetenv(name)
+			}
+		})
+
+		It("reports the build to the stage instead of triggering the pipeline", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+
+			var response concourse.OutResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version).To(Equal(checkVersion(execution)))
+			Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Stage Id", Value: "STAGE1"}))
+			Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Reported status", Value: "failed"}))
+		})
+
+		Context("when a properties file is given", func() {
+			BeforeEach(func() {
+				request.Params.ReportStage.Status = ""
+				request.Params.ReportStage.PropertiesFile = filepath.Join(getDir, "properties.yml")
+				gateServer.SetHandler(3, ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", "/pipelines/EX1/stages/STAGE1"),
+					ghttp.VerifyJSON(`{
+						"concourseReport": {
+							"buildInfo": {
+								"job": "build",
+								"name": "7",
+								"number": 7,
+								"url": "https://ci.example.com/teams/main/pipelines/app/jobs/build/builds/7",
+								"result": "SUCCEEDED",
+								"building": false
+							},
+							"propertyFileContents": {"image": "org/app:1.0", "replicas": 3}
+						}
+					}`),
+					ghttp.RespondWith(200, nil),
+				))
+			})
+
+			It("reports the properties with the build, which succeeded by default", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("when the execution has no concourse stage", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(getDir, "stage_id"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("put step failed: " + getDir + " has no stage_id, the execution has no concourse stage to report to"))
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the status is not a build status", func() {
+			BeforeEach(func() {
+				request.Params.ReportStage.Status = "SUCCESS"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("put step failed: report_stage.status must be one of succeeded, failed, errored, aborted, got SUCCESS"))
+			})
+		})
+	})
+})
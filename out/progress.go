@@ -0,0 +1,100 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// progressReporter renders the stages of an execution to the build log as they change
+// between polls, and a summary table once the put stops waiting.
+type progressReporter struct {
+	w        io.Writer
+	now      func() time.Time
+	statuses map[string]string
+	last     spinnaker.PipelineExecution
+}
+
+func newProgressReporter(w io.Writer) *progressReporter {
+	return &progressReporter{
+		w:        w,
+		now:      time.Now,
+		statuses: map[string]string{},
+	}
+}
+
+// update prints every stage whose status changed since the previous poll.
+func (p *progressReporter) update(execution spinnaker.PipelineExecution) {
+	p.last = execution
+	for _, stage := range execution.Stages {
+		key := stage.ID
+		if key == "" {
+			key = stage.RefID
+		}
+		if p.statuses[key] == stage.Status {
+			continue
+		}
+		p.statuses[key] = stage.Status
+
+		line := fmt.Sprintf("[%s] %s (%s)", stage.Status, stage.Name, stage.Type)
+		if stage.EndTime > 0 {
+			line += " in " + p.duration(stage)
+		}
+		if message := failureMessage(stage); message != "" {
+			line += ": " + message
+		}
+		concourse.Sayf(p.w, "%s\n", line)
+	}
+}
+
+// summary prints a table of all stages of the last polled execution.
+func (p *progressReporter) summary() {
+	if len(p.last.Stages) == 0 {
+		return
+	}
+	concourse.Sayf(p.w, "\nPipeline execution %s: %s\n", p.last.ID, p.last.Status)
+	table := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "STAGE\tTYPE\tSTATUS\tDURATION")
+	for _, stage := range p.last.Stages {
+		duration := "-"
+		if stage.StartTime > 0 {
+			duration = p.duration(stage)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", stage.Name, stage.Type, stage.Status, duration)
+	}
+	table.Flush()
+}
+
+func (p *progressReporter) duration(stage spinnaker.Stage) string {
+	end := stage.EndTime
+	if end == 0 {
+		end = p.now().UnixNano() / int64(time.Millisecond)
+	}
+	return (time.Duration(end-stage.StartTime) * time.Millisecond).Round(time.Second).String()
+}
+
+// failureMessage returns the errors Spinnaker recorded for a failed stage, if any.
+func failureMessage(stage spinnaker.Stage) string {
+	exception, ok := stage.Context.Exception()
+	if !ok {
+		return ""
+	}
+	if len(exception.Details.Errors) > 0 {
+		return strings.Join(exception.Details.Errors, "; ")
+	}
+	return exception.Details.Error
+}
@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	"github.com/hellofresh/spinnaker-resource/trigger"
+)
+
+const defaultBuildStatus = "succeeded"
+
+// buildStatuses are the statuses a Concourse build can finish with.
+var buildStatuses = []string{"succeeded", "failed", "errored", "aborted"}
+
+// reportStage reports the status of the build, and the properties of report_stage.properties_file,
+// to the concourse stage whose execution and stage id a get step wrote to report_stage.dir.
+func reportStage(stdout, stderr io.Writer, spinClient spinnaker.SpinClient, sourcesDir string, request concourse.OutRequest) error {
+	params := request.Params.ReportStage
+	if params.Dir == "" {
+		return errors.New("report_stage.dir must be set")
+	}
+	status := params.Status
+	if status == "" {
+		status = defaultBuildStatus
+	}
+	if !checkStatus(status, buildStatuses) {
+		return fmt.Errorf("report_stage.status must be one of %s, got %s", strings.Join(buildStatuses, ", "), status)
+	}
+
+	dir := filepath.Join(sourcesDir, params.Dir)
+	pipelineExecutionID, err := readIDFile(dir, "version")
+	if err != nil {
+		return err
+	}
+	stageID, err := readIDFile(dir, "stage_id")
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s has no stage_id, the execution has no concourse stage to report to", params.Dir)
+	} else if err != nil {
+		return err
+	}
+
+	pipelineExecution, err := spinClient.GetPipelineExecution(pipelineExecutionID)
+	if err != nil {
+		return err
+	}
+
+	build := trigger.NewBuilder(request.Params, sourcesDir).Metadata()
+	report := spinnaker.StageReport{
+		BuildInfo: spinnaker.ReportedBuild{
+			Job:    build.Job,
+			Number: build.BuildNumber,
+			Result: strings.ToUpper(status),
+		},
+	}
+	if build.BuildInfo != nil {
+		report.BuildInfo.Name = build.BuildInfo.Name
+		report.BuildInfo.URL = build.BuildInfo.URL
+	}
+	if params.PropertiesFile != "" {
+		report.PropertyFileContents, err = trigger.ReadParamsFile(filepath.Join(sourcesDir, params.PropertiesFile))
+		if err != nil {
+			return err
+		}
+	}
+
+	concourse.Sayf(stderr, "Reporting build %s to stage %s of pipeline execution %s\n", status, stageID, pipelineExecutionID)
+
+	if err = spinClient.ReportConcourseStage(pipelineExecutionID, stageID, report); err != nil {
+		return err
+	}
+
+	metadata := executionMetadata(request.Source, pipelineExecution)
+	metadata = append(metadata,
+		concourse.MetadataPair{Name: "Stage Id", Value: stageID},
+		concourse.MetadataPair{Name: "Reported status", Value: status},
+	)
+	return concourse.WriteResponse(stdout, concourse.OutResponse{
+		Version:  pipelineExecution.Version(),
+		Metadata: metadata,
+	})
+}
+
+// readIDFile reads an id a get step wrote to a file of dir.
+func readIDFile(dir, name string) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(contents))
+	if id == "" {
+		return "", fmt.Errorf("%s is empty", filepath.Join(dir, name))
+	}
+	return id, nil
+}
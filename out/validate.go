@@ -0,0 +1,153 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// validateTrigger reports every way the trigger does not match the pipeline config at once,
+// as found by parameterProblems and artifactProblems.
+func validateTrigger(config spinnaker.PipelineConfig, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("trigger does not match the config of pipeline %s: %s", config.Name, strings.Join(problems, "; "))
+}
+
+// withParameterDefaults adds the declared default of every parameter that is not given.
+func withParameterDefaults(config spinnaker.PipelineConfig, parameters map[string]interface{}) map[string]interface{} {
+	for _, parameter := range config.ParameterConfig {
+		if _, ok := parameters[parameter.Name]; !ok && parameter.Default != "" {
+			if parameters == nil {
+				parameters = map[string]interface{}{}
+			}
+			parameters[parameter.Name] = string(parameter.Default)
+		}
+	}
+	return parameters
+}
+
+// parameterProblems checks the trigger parameters against the parameterConfig of the pipeline:
+// every parameter must be declared when the pipeline declares any, required parameters without
+// a default must be given and parameters with options must be one of them.
+func parameterProblems(config spinnaker.PipelineConfig, parameters map[string]interface{}) []string {
+	var problems []string
+
+	declared := map[string]bool{}
+	for _, parameter := range config.ParameterConfig {
+		declared[parameter.Name] = true
+
+		value, ok := parameters[parameter.Name]
+		if !ok {
+			if parameter.Required && parameter.Default == "" {
+				problems = append(problems, fmt.Sprintf("missing required parameter %s", parameter.Name))
+			}
+			continue
+		}
+
+		if parameter.HasOptions && len(parameter.Options) > 0 && !isOption(parameter, paramString(value)) {
+			options := make([]string, len(parameter.Options))
+			for i, option := range parameter.Options {
+				options[i] = string(option.Value)
+			}
+			problems = append(problems, fmt.Sprintf("parameter %s is %q, must be one of: %s", parameter.Name, paramString(value), strings.Join(options, ", ")))
+		}
+	}
+
+	// Pipelines that declare no parameters read them through ${trigger.parameters}, so any is allowed.
+	if len(config.ParameterConfig) == 0 {
+		return problems
+	}
+	var unknown []string
+	for name := range parameters {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown parameter %s", name))
+	}
+
+	return problems
+}
+
+func isOption(parameter spinnaker.ParameterConfig, value string) bool {
+	for _, option := range parameter.Options {
+		if string(option.Value) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// artifactProblems checks that every expected artifact of the pipeline that has no default or
+// prior artifact to fall back on is matched by one of the trigger artifacts.
+func artifactProblems(config spinnaker.PipelineConfig, artifacts []spinnaker.Artifact) []string {
+	var problems []string
+	for _, expected := range config.ExpectedArtifacts {
+		if expected.MatchArtifact == nil || expected.UseDefaultArtifact || expected.UsePriorArtifact {
+			continue
+		}
+		if !anyArtifactMatches(*expected.MatchArtifact, artifacts) {
+			name := expected.DisplayName
+			if name == "" {
+				name = expected.ID
+			}
+			problems = append(problems, fmt.Sprintf("no artifact matches expected artifact %s", name))
+		}
+	}
+	return problems
+}
+
+// anyArtifactMatches matches artifacts like Spinnaker does: every field set on the expected
+// artifact is a regular expression that must match the whole field of the artifact.
+func anyArtifactMatches(expected spinnaker.Artifact, artifacts []spinnaker.Artifact) bool {
+	for _, artifact := range artifacts {
+		if fieldMatches(expected.Type, artifact.Type) &&
+			fieldMatches(expected.Name, artifact.Name) &&
+			fieldMatches(expected.Version, artifact.Version) &&
+			fieldMatches(expected.Location, artifact.Location) &&
+			fieldMatches(expected.Reference, artifact.Reference) {
+			return true
+		}
+	}
+	return false
+}
+
+func fieldMatches(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return pattern == value
+	}
+	return re.MatchString(value)
+}
+
+// paramString is the value of a parameter as Spinnaker compares it with its options.
+func paramString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bytes)
+}
@@ -0,0 +1,123 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+var defaultStageStatuses = []string{"SUCCEEDED"}
+
+// waitCondition is what the put step waits for after triggering the pipeline.
+type waitCondition interface {
+	// reached reports whether the execution is in the awaited state, or returns an
+	// error once it can no longer get there.
+	reached(execution spinnaker.PipelineExecution) (bool, error)
+	fmt.Stringer
+}
+
+// newWaitCondition returns the condition configured by the request, or nil when the put
+// step should not wait. wait_for_stage takes precedence over the source statuses.
+func newWaitCondition(request concourse.OutRequest) (waitCondition, error) {
+	if stage := request.Params.WaitForStage; stage != nil {
+		if stage.RefID == "" && stage.Name == "" {
+			return nil, errors.New("wait_for_stage requires ref_id or name")
+		}
+		statuses := stage.Statuses
+		if len(statuses) == 0 {
+			statuses = defaultStageStatuses
+		}
+		return stageStatusCondition{refID: stage.RefID, name: stage.Name, statuses: statuses}, nil
+	}
+	if len(request.Source.Statuses) > 0 {
+		return pipelineStatusCondition{statuses: request.Source.Statuses}, nil
+	}
+	return nil, nil
+}
+
+type pipelineStatusCondition struct {
+	statuses []string
+}
+
+func (c pipelineStatusCondition) reached(execution spinnaker.PipelineExecution) (bool, error) {
+	if checkStatus(execution.Status, c.statuses) {
+		return true, nil
+	}
+	if !isRunning(execution.Status) {
+		return false, fmt.Errorf("Pipeline execution reached a final state: %s", execution.Status)
+	}
+	return false, nil
+}
+
+func (c pipelineStatusCondition) String() string {
+	return "configured status(es)"
+}
+
+type stageStatusCondition struct {
+	refID, name string
+	statuses    []string
+}
+
+func (c stageStatusCondition) reached(execution spinnaker.PipelineExecution) (bool, error) {
+	stage, found := c.find(execution.Stages)
+	if found {
+		if checkStatus(stage.Status, c.statuses) {
+			return true, nil
+		}
+		if isFinalStageStatus(stage.Status) {
+			return false, fmt.Errorf("Stage %s reached a final state: %s", c.describe(), stage.Status)
+		}
+	}
+	if !isRunning(execution.Status) {
+		return false, fmt.Errorf("Pipeline execution reached a final state: %s before stage %s reached status(es) %s", execution.Status, c.describe(), strings.Join(c.statuses, ", "))
+	}
+	return false, nil
+}
+
+func (c stageStatusCondition) find(stages []spinnaker.Stage) (spinnaker.Stage, bool) {
+	for _, stage := range stages {
+		if c.refID != "" && stage.RefID == c.refID {
+			return stage, true
+		}
+		if c.refID == "" && stage.Name == c.name {
+			return stage, true
+		}
+	}
+	return spinnaker.Stage{}, false
+}
+
+func (c stageStatusCondition) describe() string {
+	if c.refID != "" {
+		return fmt.Sprintf("with refId %q", c.refID)
+	}
+	return fmt.Sprintf("%q", c.name)
+}
+
+func (c stageStatusCondition) String() string {
+	return fmt.Sprintf("stage %s to reach status(es) %s", c.describe(), strings.Join(c.statuses, ", "))
+}
+
+// isRunning reports whether an execution has not reached a final state yet.
+func isRunning(status string) bool {
+	return status == "RUNNING" || status == "NOT_STARTED" || status == "BUFFERED"
+}
+
+func isFinalStageStatus(status string) bool {
+	switch status {
+	case "SUCCEEDED", "FAILED_CONTINUE", "TERMINAL", "CANCELED", "STOPPED", "SKIPPED":
+		return true
+	}
+	return false
+}
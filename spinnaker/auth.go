@@ -0,0 +1,220 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+)
+
+const (
+	AuthTypeX509   = "x509"
+	AuthTypeBasic  = "basic"
+	AuthTypeBearer = "bearer"
+	AuthTypeOAuth2 = "oauth2"
+)
+
+// tokens are refreshed this long before they expire, so a request never races the expiry
+const tokenExpiryDelta = 30 * time.Second
+
+const tokenRequestTimeout = time.Minute
+
+// newTransport returns the round tripper chain used for every Gate request, selected by
+// the source auth block. Without an auth block the client authenticates with x509.
+func newTransport(source concourse.Source) (http.RoundTripper, error) {
+	authType := source.Auth.Type
+	if authType == "" {
+		authType = AuthTypeX509
+	}
+
+	tlsConfig, err := newTLSConfig(source, authType == AuthTypeX509)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &http.Transport{
+		TLSClientConfig: tlsConfig,
+	}
+
+	switch authType {
+	case AuthTypeX509:
+		return base, nil
+	case AuthTypeBasic:
+		if source.Auth.Username == "" || source.Auth.Password == "" {
+			return nil, fmt.Errorf("auth type %s requires username and password", authType)
+		}
+		return &basicAuthTransport{
+			username: source.Auth.Username,
+			password: source.Auth.Password,
+			next:     base,
+		}, nil
+	case AuthTypeBearer:
+		if source.Auth.Token == "" {
+			return nil, fmt.Errorf("auth type %s requires token", authType)
+		}
+		return &bearerTokenTransport{
+			tokens: staticToken(source.Auth.Token),
+			next:   base,
+		}, nil
+	case AuthTypeOAuth2:
+		if source.Auth.TokenURL == "" || source.Auth.ClientID == "" || source.Auth.ClientSecret == "" {
+			return nil, fmt.Errorf("auth type %s requires token_url, client_id and client_secret", authType)
+		}
+		client, err := newTokenClient(source.Auth.CACert)
+		if err != nil {
+			return nil, err
+		}
+		return &bearerTokenTransport{
+			tokens: &clientCredentialsTokenSource{
+				tokenURL:     source.Auth.TokenURL,
+				clientID:     source.Auth.ClientID,
+				clientSecret: source.Auth.ClientSecret,
+				scopes:       source.Auth.Scopes,
+				client:       client,
+			},
+			next: base,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported auth type %q, must be one of: %s, %s, %s, %s", authType, AuthTypeX509, AuthTypeBasic, AuthTypeBearer, AuthTypeOAuth2)
+	}
+}
+
+// newTokenClient returns the client for the OAuth2 token endpoint. The endpoint is usually not
+// served by Gate, so none of the Gate TLS settings apply to it and its certificate is verified
+// against the system roots, or against auth.ca_cert when it is set.
+func newTokenClient(caCert string) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, fmt.Errorf("auth.ca_cert does not contain any valid PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+		Timeout: tokenRequestTimeout,
+	}, nil
+}
+
+type basicAuthTransport struct {
+	username, password string
+	next               http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.username, t.password)
+	return t.next.RoundTrip(req)
+}
+
+type tokenSource interface {
+	Token() (string, error)
+}
+
+type staticToken string
+
+func (t staticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+type bearerTokenTransport struct {
+	tokens tokenSource
+	next   http.RoundTripper
+}
+
+func (t *bearerTokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.next.RoundTrip(req)
+}
+
+// clientCredentialsTokenSource fetches tokens with the OAuth2 client credentials grant
+// and caches them until shortly before they expire.
+type clientCredentialsTokenSource struct {
+	tokenURL, clientID, clientSecret string
+	scopes                           []string
+	client                           *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (s *clientCredentialsTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expires.IsZero() || time.Now().Before(s.expires)) {
+		return s.token, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+	req, err := http.NewRequest("POST", s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	response, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching oauth2 token: %s", err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("fetching oauth2 token: %s", err)
+	}
+	if response.StatusCode >= 400 {
+		return "", fmt.Errorf("oauth2 token endpoint responded with status code: %d, body: %s", response.StatusCode, string(body))
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("decoding oauth2 token response: %s", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token endpoint did not return an access_token")
+	}
+
+	s.token = token.AccessToken
+	s.expires = time.Time{}
+	if token.ExpiresIn > 0 {
+		s.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryDelta)
+	}
+	return s.token, nil
+}
@@ -0,0 +1,341 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Authentication", func() {
+	var (
+		gateServer      *ghttp.Server
+		source          concourse.Source
+		verifyAuth      http.HandlerFunc
+		clientErr       error
+		gateApplication = "existent_app"
+	)
+
+	BeforeEach(func() {
+		gateServer = ghttp.NewServer()
+		source = concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: gateApplication,
+			SpinnakerPipeline:    "existent_pipeline",
+		}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		gateServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/applications/"+gateApplication),
+				verifyAuth,
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": gateApplication}),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/applications/"+gateApplication+"/pipelineConfigs"),
+				verifyAuth,
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+			),
+		)
+		_, clientErr = spinnaker.NewClient(source)
+	})
+
+	Context("when no auth block is configured", func() {
+		BeforeEach(func() {
+			verifyAuth = func(http.ResponseWriter, *http.Request) {}
+		})
+
+		It("requires an x509 client certificate", func() {
+			Expect(clientErr).To(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+		})
+
+		Context("when an x509 client certificate is configured", func() {
+			BeforeEach(func() {
+				source.X509Cert = serverCert
+				source.X509Key = serverKey
+			})
+
+			It("creates the client", func() {
+				Expect(clientErr).ToNot(HaveOccurred())
+			})
+		})
+	})
+
+	Context("when basic auth is configured", func() {
+		BeforeEach(func() {
+			source.Auth = concourse.Auth{
+				Type:     "basic",
+				Username: "ldap-user",
+				Password: "ldap-password",
+			}
+			verifyAuth = ghttp.VerifyBasicAuth("ldap-user", "ldap-password")
+		})
+
+		It("sends the credentials with every request", func() {
+			Expect(clientErr).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		Context("when the password is missing", func() {
+			BeforeEach(func() {
+				source.Auth.Password = ""
+			})
+
+			It("returns an error", func() {
+				Expect(clientErr).To(MatchError("auth type basic requires username and password"))
+			})
+		})
+	})
+
+	Context("when a bearer token is configured", func() {
+		BeforeEach(func() {
+			source.Auth = concourse.Auth{
+				Type:  "bearer",
+				Token: "static-token",
+			}
+			verifyAuth = ghttp.VerifyHeaderKV("Authorization", "Bearer static-token")
+		})
+
+		It("sends the token with every request", func() {
+			Expect(clientErr).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when oauth2 client credentials are configured", func() {
+		var tokenServer *ghttp.Server
+
+		BeforeEach(func() {
+			tokenServer = ghttp.NewServer()
+			source.Auth = concourse.Auth{
+				Type:         "oauth2",
+				TokenURL:     tokenServer.URL() + "/oauth/token",
+				ClientID:     "concourse",
+				ClientSecret: "s3cr3t",
+				Scopes:       []string{"spinnaker", "openid"},
+			}
+			verifyAuth = ghttp.VerifyHeaderKV("Authorization", "Bearer fetched-token")
+		})
+
+		AfterEach(func() {
+			tokenServer.Close()
+		})
+
+		Context("when the token endpoint issues a token", func() {
+			BeforeEach(func() {
+				tokenServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						ghttp.VerifyBasicAuth("concourse", "s3cr3t"),
+						ghttp.VerifyContentType("application/x-www-form-urlencoded"),
+						ghttp.VerifyForm(map[string][]string{
+							"grant_type": {"client_credentials"},
+							"scope":      {"spinnaker openid"},
+						}),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"access_token": "fetched-token",
+							"token_type":   "bearer",
+							"expires_in":   3600,
+						}),
+					),
+				)
+			})
+
+			It("fetches the token once and reuses it for every request", func() {
+				Expect(clientErr).ToNot(HaveOccurred())
+				Expect(tokenServer.ReceivedRequests()).To(HaveLen(1))
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("when the token endpoint rejects the client", func() {
+			BeforeEach(func() {
+				tokenServer.AppendHandlers(
+					ghttp.RespondWithJSONEncoded(401, map[string]interface{}{"error": "invalid_client"}),
+				)
+			})
+
+			It("returns an error without calling spinnaker", func() {
+				Expect(clientErr).To(HaveOccurred())
+				Expect(clientErr.Error()).To(ContainSubstring("oauth2 token endpoint responded with status code: 401"))
+				Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when an unknown auth type is configured", func() {
+		BeforeEach(func() {
+			source.Auth = concourse.Auth{Type: "kerberos"}
+		})
+
+		It("returns an error", func() {
+			Expect(clientErr).To(HaveOccurred())
+			Expect(clientErr.Error()).To(ContainSubstring(`unsupported auth type "kerberos"`))
+		})
+	})
+})
+
+var _ = Describe("OAuth2 token endpoint", func() {
+	var (
+		gateServer, tokenServer *ghttp.Server
+		certFile                string
+		source                  concourse.Source
+		clientErr               error
+	)
+
+	BeforeEach(func() {
+		// The token endpoint has a certificate of its own, trusted through the system roots.
+		tokenCert, tokenCertPEM := selfSignedCert()
+		file, err := ioutil.TempFile("", "roots")
+		Expect(err).ToNot(HaveOccurred())
+		_, err = file.Write(tokenCertPEM)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		certFile = file.Name()
+		os.Setenv("SSL_CERT_FILE", certFile)
+
+		tokenServer = ghttp.NewUnstartedServer()
+		tokenServer.HTTPTestServer.TLS = &tls.Config{
+			Certificates: []tls.Certificate{tokenCert},
+			ClientAuth:   tls.RequestClientCert,
+		}
+		tokenServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/oauth/token"),
+				func(_ http.ResponseWriter, req *http.Request) {
+					Expect(req.TLS.PeerCertificates).To(BeEmpty(), "the x509 client certificate is only for Gate")
+				},
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"access_token": "fetched-token"}),
+			),
+		)
+
+		gateServer = ghttp.NewUnstartedServer()
+		gateServer.HTTPTestServer.StartTLS()
+		gateServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "Bearer fetched-token"),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyHeaderKV("Authorization", "Bearer fetched-token"),
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+			),
+		)
+
+		source = concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: "existent_app",
+			SpinnakerPipeline:    "existent_pipeline",
+			CACert:               string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: gateServer.HTTPTestServer.Certificate().Raw})),
+			ServerName:           "example.com",
+			X509Cert:             serverCert,
+			X509Key:              serverKey,
+			Auth: concourse.Auth{
+				Type:         "oauth2",
+				ClientID:     "concourse",
+				ClientSecret: "s3cr3t",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+		tokenServer.Close()
+		os.Unsetenv("SSL_CERT_FILE")
+		os.Remove(certFile)
+	})
+
+	JustBeforeEach(func() {
+		tokenServer.HTTPTestServer.StartTLS()
+		source.Auth.TokenURL = tokenServer.URL() + "/oauth/token"
+		_, clientErr = spinnaker.NewClient(source)
+	})
+
+	Context("when spinnaker_server_name and spinnaker_ca_cert are set for Gate", func() {
+		It("fetches the token without the Gate TLS settings", func() {
+			Expect(clientErr).ToNot(HaveOccurred())
+			Expect(tokenServer.ReceivedRequests()).To(HaveLen(1))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when the token endpoint has a certificate of a private CA", func() {
+		BeforeEach(func() {
+			privateCert, privateCertPEM := selfSignedCert()
+			tokenServer.HTTPTestServer.TLS.Certificates = []tls.Certificate{privateCert}
+			source.Auth.CACert = string(privateCertPEM)
+		})
+
+		It("verifies it against auth.ca_cert", func() {
+			Expect(clientErr).ToNot(HaveOccurred())
+			Expect(tokenServer.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		Context("when auth.ca_cert is not PEM encoded", func() {
+			BeforeEach(func() {
+				source.Auth.CACert = "not a certificate"
+			})
+
+			It("returns an error", func() {
+				Expect(clientErr).To(MatchError("auth.ca_cert does not contain any valid PEM encoded certificates"))
+				Expect(tokenServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+})
+
+// selfSignedCert returns a certificate for 127.0.0.1 that is its own CA.
+func selfSignedCert() (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).ToNot(HaveOccurred())
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"Token Issuer"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).ToNot(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).ToNot(HaveOccurred())
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	cert, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	Expect(err).ToNot(HaveOccurred())
+	return cert, certPEM
+}
@@ -10,45 +10,54 @@ Unless required by applicable law or agreed to in writing, software distributed
 package spinnaker
 
 import (
-	"bytes"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
 
 	"github.com/hellofresh/spinnaker-resource/concourse"
 )
 
+const defaultExecutionLimit = 25
+
+// MaxExecutionWindow is the most executions GetPipelineExecutions widens its window to while
+// looking for the previous version.
+const MaxExecutionWindow = 1000
+
 type SpinClient struct {
-	sourceConfig concourse.Source
-	client       *http.Client
+	sourceConfig   concourse.Source
+	client         *http.Client
+	retry          retryPolicy
+	pipelineConfig PipelineConfig
 }
 
 func NewClient(source concourse.Source) (SpinClient, error) {
 
-	cert, err := tls.X509KeyPair([]byte(source.X509Cert), []byte(source.X509Key))
-
+	tr, err := newTransport(source)
 	if err != nil {
 		return SpinClient{}, err
 	}
-	tlsConfig := &tls.Config{
-		MinVersion:               tls.VersionTLS12,
-		PreferServerCipherSuites: true,
-		Certificates:             []tls.Certificate{cert},
-		//TODO Do something!!
-		InsecureSkipVerify: true,
+
+	timeout, err := parseDuration("request_timeout", source.RequestTimeout, defaultRequestTimeout)
+	if err != nil {
+		return SpinClient{}, err
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig: tlsConfig,
+	retry, err := newRetryPolicy(source)
+	if err != nil {
+		return SpinClient{}, err
 	}
 
-	client := &http.Client{Transport: tr}
+	spinClient := SpinClient{
+		sourceConfig: source,
+		client:       &http.Client{Transport: tr, Timeout: timeout},
+		retry:        retry,
+	}
 
-	res, err := client.Get(fmt.Sprintf("%s/applications/%s", source.SpinnakerAPI, source.SpinnakerApplication))
+	res, err := spinClient.get(fmt.Sprintf("%s/applications/%s", source.SpinnakerAPI, source.SpinnakerApplication))
 	if err != nil {
 		return SpinClient{}, err
 	} else if res.StatusCode == 404 {
@@ -62,7 +71,7 @@ func NewClient(source concourse.Source) (SpinClient, error) {
 		return SpinClient{}, err
 	}
 
-	res, err = client.Get(fmt.Sprintf("%s/applications/%s/pipelineConfigs", source.SpinnakerAPI, source.SpinnakerApplication))
+	res, err = spinClient.get(fmt.Sprintf("%s/applications/%s/pipelineConfigs", source.SpinnakerAPI, source.SpinnakerApplication))
 	if err != nil {
 		return SpinClient{}, err
 	} else if res.StatusCode >= 400 {
@@ -72,53 +81,90 @@ func NewClient(source concourse.Source) (SpinClient, error) {
 			return SpinClient{}, err
 		}
 	} else {
-		var pipelineConfigs []map[string]interface{}
+		var pipelineConfigs []PipelineConfig
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return SpinClient{}, err
 		}
 
 		err = json.Unmarshal(body, &pipelineConfigs)
+		if err != nil {
+			return SpinClient{}, fmt.Errorf("decoding pipeline configs of application %s: %s", source.SpinnakerApplication, err)
+		}
+
+		spinClient.pipelineConfig, err = findPipelineConfig(source, pipelineConfigs)
 		if err != nil {
 			return SpinClient{}, err
 		}
+	}
+
+	return spinClient, nil
+}
 
-		found := false
+// findPipelineConfig finds the pipeline config configured by spinnaker_pipeline_id or, if
+// that is not set, spinnaker_pipeline.
+func findPipelineConfig(source concourse.Source, pipelineConfigs []PipelineConfig) (PipelineConfig, error) {
+	if source.SpinnakerPipelineID == "" {
+		if source.SpinnakerPipeline == "" {
+			return PipelineConfig{}, fmt.Errorf("one of spinnaker_pipeline or spinnaker_pipeline_id must be set")
+		}
 		for _, pc := range pipelineConfigs {
-			if pc["name"].(string) == source.SpinnakerPipeline {
-				found = true
-				break
+			if pc.Name == source.SpinnakerPipeline {
+				return pc, nil
 			}
 		}
-		if !found {
-			err = fmt.Errorf("spinnaker pipeline %s not found", source.SpinnakerPipeline)
-			return SpinClient{}, err
-		}
+		return PipelineConfig{}, fmt.Errorf("spinnaker pipeline %s not found", source.SpinnakerPipeline)
 	}
 
-	spinClient := SpinClient{
-		sourceConfig: source,
-		client:       client,
+	for _, pc := range pipelineConfigs {
+		if pc.ID != source.SpinnakerPipelineID {
+			continue
+		}
+		if source.SpinnakerPipeline != "" && source.SpinnakerPipeline != pc.Name {
+			return PipelineConfig{}, fmt.Errorf("spinnaker_pipeline %s does not match spinnaker_pipeline_id %s, which is named %s", source.SpinnakerPipeline, pc.ID, pc.Name)
+		}
+		return pc, nil
 	}
-	return spinClient, nil
+	return PipelineConfig{}, fmt.Errorf("spinnaker pipeline with id %s not found", source.SpinnakerPipelineID)
+}
+
+// Source returns the source the client was created with, with spinnaker_pipeline and
+// spinnaker_pipeline_id resolved from the pipeline config.
+func (c *SpinClient) Source() concourse.Source {
+	source := c.sourceConfig
+	source.SpinnakerPipeline = c.pipelineConfig.Name
+	source.SpinnakerPipelineID = c.pipelineConfig.ID
+	return source
 }
 
-func (c *SpinClient) GetPipelineExecution(pipelineExecutionID string) (map[string]interface{}, error) {
-	var pipelineExecutionMetadata map[string]interface{}
+// PipelineConfig returns the config of the pipeline the client was created for.
+func (c *SpinClient) PipelineConfig() PipelineConfig {
+	return c.pipelineConfig
+}
+
+func (c *SpinClient) GetPipelineExecution(pipelineExecutionID string) (PipelineExecution, error) {
 	bytes, err := c.GetPipelineExecutionRaw(pipelineExecutionID)
 	if err != nil {
-		return nil, err
+		return PipelineExecution{}, err
 	}
-	err = json.Unmarshal(bytes, &pipelineExecutionMetadata)
-	if err != nil {
-		return nil, err
+	return DecodePipelineExecution(bytes)
+}
+
+// DecodePipelineExecution decodes a pipeline execution as returned by GetPipelineExecutionRaw.
+func DecodePipelineExecution(raw []byte) (PipelineExecution, error) {
+	var pipelineExecution PipelineExecution
+	if err := json.Unmarshal(raw, &pipelineExecution); err != nil {
+		return PipelineExecution{}, fmt.Errorf("decoding pipeline execution: %s", err)
+	}
+	if pipelineExecution.ID == "" {
+		return PipelineExecution{}, fmt.Errorf("decoding pipeline execution: response has no execution id")
 	}
-	return pipelineExecutionMetadata, nil
+	return pipelineExecution, nil
 }
 
 func (c *SpinClient) GetPipelineExecutionRaw(pipelineExecutionID string) ([]byte, error) {
 	url := fmt.Sprintf("%s/pipelines/%s", c.sourceConfig.SpinnakerAPI, pipelineExecutionID)
-	response, err := c.client.Get(url)
+	response, err := c.get(url)
 	if err != nil {
 		return nil, err
 	} else if response.StatusCode == 404 {
@@ -138,14 +184,47 @@ func (c *SpinClient) GetPipelineExecutionRaw(pipelineExecutionID string) ([]byte
 	return body, nil
 }
 
-//returns the last 25 spinnaker pipeline executions
-func (c *SpinClient) GetPipelineExecutions() ([]PipelineExecution, error) {
+// GetPipelineExecutions returns the most recent executions of the configured pipeline,
+// newest first. The first execution_limit executions are fetched, and when untilRef is
+// set and not among them, the window is doubled until it is found, the pipeline has no
+// older executions, or MaxExecutionWindow is reached.
+func (c *SpinClient) GetPipelineExecutions(untilRef string) ([]PipelineExecution, error) {
+	window := c.sourceConfig.ExecutionLimit
+	if window <= 0 {
+		window = defaultExecutionLimit
+	}
+
+	for {
+		pipelineExecutions, err := c.getPipelineExecutionsWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		if untilRef == "" || len(pipelineExecutions) < window || window >= MaxExecutionWindow {
+			return pipelineExecutions, nil
+		}
+		for _, execution := range pipelineExecutions {
+			if execution.ID == untilRef {
+				return pipelineExecutions, nil
+			}
+		}
+
+		window *= 2
+		if window > MaxExecutionWindow {
+			window = MaxExecutionWindow
+		}
+	}
+}
+
+func (c *SpinClient) getPipelineExecutionsWindow(limit int) ([]PipelineExecution, error) {
 	var pipelineExecutions []PipelineExecution
 
-	//TODO What does expand do ??
-	url := fmt.Sprintf("%s/applications/%s/pipelines?limit=25", c.sourceConfig.SpinnakerAPI, c.sourceConfig.SpinnakerApplication)
+	if c.pipelineConfig.ID == "" {
+		return nil, fmt.Errorf("spinnaker pipeline %s has no pipeline config id", c.pipelineConfig.Name)
+	}
+
+	url := fmt.Sprintf("%s/executions?pipelineConfigIds=%s&limit=%d", c.sourceConfig.SpinnakerAPI, url.QueryEscape(c.pipelineConfig.ID), limit)
 
-	if response, err := c.client.Get(url); err != nil {
+	if response, err := c.get(url); err != nil {
 		return nil, err
 	} else if response.StatusCode >= 400 {
 		body, err := ioutil.ReadAll(response.Body)
@@ -160,22 +239,25 @@ func (c *SpinClient) GetPipelineExecutions() ([]PipelineExecution, error) {
 		}
 		err = json.Unmarshal(body, &pipelineExecutions)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("decoding pipeline executions: %s", err)
 		}
 		return pipelineExecutions, nil
 	}
 }
 
+// GetPipelineExecutionsWithRunningStage keeps the executions with a stage selected by
+// spinnaker_stage or spinnaker_stages in one of the statuses, each execution once. Without
+// selectors every execution is kept.
 func (c *SpinClient) GetPipelineExecutionsWithRunningStage(pipelineExecutions []PipelineExecution) []PipelineExecution {
+	selectors := StageSelectors(c.sourceConfig)
+	if len(selectors) == 0 {
+		return pipelineExecutions
+	}
 
 	var executions []PipelineExecution
-
 	for _, execution := range pipelineExecutions {
-		stages := execution.Stages
-		for _, stage := range stages {
-			if stage.RefID == c.sourceConfig.SpinnakerStage && InStatuses(stage.Status, c.sourceConfig.Statuses) {
-				executions = append(executions, execution)
-			}
+		if _, ok := execution.MatchingStage(selectors, c.sourceConfig.Statuses); ok {
+			executions = append(executions, execution)
 		}
 	}
 
@@ -192,13 +274,23 @@ func InStatuses(val string, statuses []string) bool {
 	return false
 }
 
+// InvokePipelineExecutionURL returns the url InvokePipelineExecution posts the trigger to.
+func (c *SpinClient) InvokePipelineExecutionURL() string {
+	if c.sourceConfig.SpinnakerPipelineID != "" {
+		// Trigger by id, so renaming the pipeline doesn't break the resource. Gate resolves the
+		// pipeline by name or id here, and starts the execution before it responds.
+		return fmt.Sprintf("%s/pipelines/%s/%s", c.sourceConfig.SpinnakerAPI, c.sourceConfig.SpinnakerApplication, c.pipelineConfig.ID)
+	}
+	return fmt.Sprintf("%s/pipelines/%s/%s", c.sourceConfig.SpinnakerAPI, c.sourceConfig.SpinnakerApplication, c.sourceConfig.SpinnakerPipeline)
+}
+
 func (c *SpinClient) InvokePipelineExecution(body []byte) (PipelineExecution, error) {
 
 	pipelineExecution := PipelineExecution{}
 
-	url := fmt.Sprintf("%s/pipelines/%s/%s", c.sourceConfig.SpinnakerAPI, c.sourceConfig.SpinnakerApplication, c.sourceConfig.SpinnakerPipeline)
+	url := c.InvokePipelineExecutionURL()
 
-	if response, err := c.client.Post(url, "application/json", bytes.NewBuffer(body)); err != nil {
+	if response, err := c.post(url, body, false); err != nil {
 		return pipelineExecution, err
 	} else if response.StatusCode >= 400 {
 		body, err := ioutil.ReadAll(response.Body)
@@ -211,22 +303,85 @@ func (c *SpinClient) InvokePipelineExecution(body []byte) (PipelineExecution, er
 		if err != nil {
 			return pipelineExecution, err
 		}
-		var Data map[string]interface{}
-		err = json.Unmarshal(body, &Data)
+		var invocation struct {
+			Ref string `json:"ref"`
+		}
+		err = json.Unmarshal(body, &invocation)
 		if err != nil {
-			return pipelineExecution, err
+			return pipelineExecution, fmt.Errorf("decoding pipeline invocation response: %s", err)
 		}
 
-		pipelineExecution.ID = strings.Split(Data["ref"].(string), "/")[2]
+		pipelineExecution.ID, err = executionIDFromRef(invocation.Ref)
+		if err != nil {
+			return pipelineExecution, err
+		}
 		return pipelineExecution, nil
 	}
 }
 
+// executionIDFromRef extracts the execution id from a ref of the form /pipelines/{id}.
+func executionIDFromRef(ref string) (string, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, "/"), "/")
+	if len(parts) != 2 || parts[0] != "pipelines" || parts[1] == "" {
+		return "", fmt.Errorf("spinnaker api returned an unexpected pipeline execution ref: %q", ref)
+	}
+	return parts[1], nil
+}
+
 func (c *SpinClient) NotifyConcourseExecution(stageId string) error {
 
 	url := fmt.Sprintf("%s/concourse/stage/start?stageId=%s&job=%s&buildNumber=%s", c.sourceConfig.SpinnakerAPI, stageId, os.Getenv("BUILD_JOB_NAME"), os.Getenv("BUILD_NAME"))
 
-	if response, err := c.client.Post(url, "application/json", bytes.NewBuffer([]byte(""))); err != nil {
+	if response, err := c.post(url, nil, false); err != nil {
+		return err
+	} else if response.StatusCode >= 400 {
+		body, err := ioutil.ReadAll(response.Body)
+		if err == nil {
+			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", response.StatusCode, string(body))
+		}
+		return err
+	}
+	return nil
+}
+
+// ReportConcourseStage merges the report of the Concourse build into the context of the
+// concourse stage of an execution, under concourseReport. Igor writes buildInfo to the context
+// while it monitors the build, so the report keeps to a key of its own. Gate has no endpoint
+// to complete the stage, so this does not complete it.
+func (c *SpinClient) ReportConcourseStage(pipelineExecutionID, stageID string, report StageReport) error {
+	body, err := json.Marshal(map[string]StageReport{"concourseReport": report})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/pipelines/%s/stages/%s", c.sourceConfig.SpinnakerAPI, pipelineExecutionID, stageID)
+
+	if response, err := c.patch(url, body); err != nil {
+		return err
+	} else if response.StatusCode >= 400 {
+		body, err := ioutil.ReadAll(response.Body)
+		if err == nil {
+			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", response.StatusCode, string(body))
+		}
+		return err
+	}
+	return nil
+}
+
+// CancelPipelineExecution cancels a running execution, recording reason as the cancellation reason.
+func (c *SpinClient) CancelPipelineExecution(pipelineExecutionID, reason string) error {
+	url := fmt.Sprintf("%s/pipelines/%s/cancel?reason=%s", c.sourceConfig.SpinnakerAPI, pipelineExecutionID, url.QueryEscape(reason))
+	return c.updatePipelineExecution(url)
+}
+
+// PausePipelineExecution pauses a running execution. It can be resumed from Deck.
+func (c *SpinClient) PausePipelineExecution(pipelineExecutionID string) error {
+	url := fmt.Sprintf("%s/pipelines/%s/pause", c.sourceConfig.SpinnakerAPI, pipelineExecutionID)
+	return c.updatePipelineExecution(url)
+}
+
+func (c *SpinClient) updatePipelineExecution(url string) error {
+	if response, err := c.put(url); err != nil {
 		return err
 	} else if response.StatusCode >= 400 {
 		body, err := ioutil.ReadAll(response.Body)
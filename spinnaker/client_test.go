@@ -209,3 +209,303 @@ var _ = Describe("Spinnaker Client", func() {
 		})
 	})
 })
+
+var _ = Describe("Pipeline config resolution", func() {
+	var (
+		gateServer *ghttp.Server
+		source     concourse.Source
+	)
+
+	BeforeEach(func() {
+		gateServer = ghttp.NewServer()
+		gateServer.AppendHandlers(
+			ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+			ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+				{"id": "PC1", "name": "existent_pipeline"},
+				{"id": "PC2", "name": "renamed_pipeline", "type": "templatedPipeline"},
+			}),
+		)
+		source = concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: "existent_app",
+			X509Cert:             serverCert,
+			X509Key:              serverKey,
+		}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	It("resolves the pipeline id from the pipeline name", func() {
+		source.SpinnakerPipeline = "existent_pipeline"
+
+		spinClient, err := spinnaker.NewClient(source)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spinClient.Source().SpinnakerPipelineID).To(Equal("PC1"))
+	})
+
+	It("accepts parameter defaults and options that are not strings", func() {
+		source.SpinnakerPipeline = "existent_pipeline"
+		gateServer.SetHandler(1, ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+			{"id": "PC1", "name": "existent_pipeline", "parameterConfig": []map[string]interface{}{
+				{"name": "replicas", "default": 3, "hasOptions": true, "options": []map[string]interface{}{{"value": 3}, {"value": 5}}},
+			}},
+			{"id": "PC2", "name": "renamed_pipeline", "parameterConfig": []map[string]interface{}{
+				{"name": "dryRun", "default": true, "options": []map[string]interface{}{{"value": true}, {"value": nil}}},
+			}},
+		}))
+
+		spinClient, err := spinnaker.NewClient(source)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spinClient.PipelineConfig().ParameterConfig).To(Equal([]spinnaker.ParameterConfig{{
+			Name:       "replicas",
+			Default:    "3",
+			HasOptions: true,
+			Options:    []spinnaker.ParameterOption{{Value: "3"}, {Value: "5"}},
+		}}))
+	})
+
+	It("resolves the pipeline name from the pipeline id", func() {
+		source.SpinnakerPipelineID = "PC2"
+
+		spinClient, err := spinnaker.NewClient(source)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spinClient.Source().SpinnakerPipeline).To(Equal("renamed_pipeline"))
+	})
+
+	It("accepts a pipeline name and id that agree", func() {
+		source.SpinnakerPipeline = "renamed_pipeline"
+		source.SpinnakerPipelineID = "PC2"
+
+		_, err := spinnaker.NewClient(source)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("returns an error when the pipeline name and id disagree", func() {
+		source.SpinnakerPipeline = "existent_pipeline"
+		source.SpinnakerPipelineID = "PC2"
+
+		_, err := spinnaker.NewClient(source)
+		Expect(err).To(MatchError("spinnaker_pipeline existent_pipeline does not match spinnaker_pipeline_id PC2, which is named renamed_pipeline"))
+	})
+
+	It("returns an error when the pipeline id does not exist", func() {
+		source.SpinnakerPipelineID = "PC3"
+
+		_, err := spinnaker.NewClient(source)
+		Expect(err).To(MatchError("spinnaker pipeline with id PC3 not found"))
+	})
+
+	It("returns an error when neither the pipeline name nor id is set", func() {
+		_, err := spinnaker.NewClient(source)
+		Expect(err).To(MatchError("one of spinnaker_pipeline or spinnaker_pipeline_id must be set"))
+	})
+
+	It("invokes the pipeline by id when the pipeline id is set", func() {
+		source.SpinnakerPipelineID = "PC2"
+		gateServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/pipelines/existent_app/PC2"),
+				ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+			),
+		)
+
+		spinClient, err := spinnaker.NewClient(source)
+		Expect(err).ToNot(HaveOccurred())
+
+		execution, err := spinClient.InvokePipelineExecution([]byte("{}"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(execution.ID).To(Equal("EX1"))
+	})
+})
+
+var _ = Describe("Pipeline executions", func() {
+	var (
+		gateServer *ghttp.Server
+		spinClient spinnaker.SpinClient
+	)
+
+	BeforeEach(func() {
+		gateServer = ghttp.NewServer()
+		gateServer.AppendHandlers(
+			ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+			ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "existent_pipeline"}}),
+		)
+
+		var err error
+		spinClient, err = spinnaker.NewClient(concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: "existent_app",
+			SpinnakerPipeline:    "existent_pipeline",
+			X509Cert:             serverCert,
+			X509Key:              serverKey,
+		})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	Context("when fetching an execution", func() {
+		It("decodes the execution, its stages and trigger", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+						"id":     "EX1",
+						"name":   "existent_pipeline",
+						"status": "TERMINAL",
+						"trigger": map[string]interface{}{
+							"type":       "concourse-resource",
+							"user":       "some-user",
+							"job":        "prod",
+							"buildInfo":  map[string]interface{}{"name": "42", "url": "https://ci.example.com/builds/42"},
+							"parameters": map[string]interface{}{"replicas": 3},
+						},
+						"stages": []map[string]interface{}{
+							{
+								"id":     "S1",
+								"refId":  "1",
+								"name":   "Deploy",
+								"type":   "deployManifest",
+								"status": "TERMINAL",
+								"context": map[string]interface{}{
+									"exception": map[string]interface{}{
+										"details": map[string]interface{}{"errors": []string{"quota exceeded"}},
+									},
+								},
+								"outputs": map[string]interface{}{"manifests": []string{"deployment"}},
+							},
+						},
+					}),
+				),
+			)
+
+			execution, err := spinClient.GetPipelineExecution("EX1")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(execution.Status).To(Equal("TERMINAL"))
+			Expect(execution.Trigger.Type).To(Equal("concourse-resource"))
+			Expect(execution.Trigger.Job).To(Equal("prod"))
+			Expect(execution.Trigger.BuildInfo).To(Equal(&spinnaker.BuildInfo{Name: "42", URL: "https://ci.example.com/builds/42"}))
+			Expect(execution.Trigger.Parameters).To(HaveKeyWithValue("replicas", BeNumerically("==", 3)))
+			Expect(execution.Stages).To(HaveLen(1))
+			Expect(execution.Stages[0].Outputs).To(HaveKey("manifests"))
+
+			exception, ok := execution.Stages[0].Context.Exception()
+			Expect(ok).To(BeTrue())
+			Expect(exception.Details.Errors).To(ConsistOf("quota exceeded"))
+		})
+
+		It("returns an error instead of panicking on an unexpected payload", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX1", "status": 42}),
+			)
+
+			_, err := spinClient.GetPipelineExecution("EX1")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("decoding pipeline execution: "))
+		})
+	})
+
+	Context("when invoking a pipeline", func() {
+		It("returns the execution id from the returned ref", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/existent_app/existent_pipeline"),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+			)
+
+			execution, err := spinClient.InvokePipelineExecution([]byte("{}"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(execution.ID).To(Equal("EX1"))
+		})
+
+		It("returns an error instead of panicking on a malformed ref", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "EX1"}),
+			)
+
+			_, err := spinClient.InvokePipelineExecution([]byte("{}"))
+			Expect(err).To(MatchError(`spinnaker api returned an unexpected pipeline execution ref: "EX1"`))
+		})
+	})
+
+	Context("when listing executions", func() {
+		var executions []map[string]interface{}
+
+		BeforeEach(func() {
+			executions = []map[string]interface{}{}
+			for _, id := range []string{"EX5", "EX4", "EX3", "EX2", "EX1"} {
+				executions = append(executions, map[string]interface{}{"id": id, "name": "existent_pipeline"})
+			}
+		})
+
+		It("queries the executions of the pipeline config", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=25"),
+					ghttp.RespondWithJSONEncoded(200, executions),
+				),
+			)
+
+			pipelineExecutions, err := spinClient.GetPipelineExecutions("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pipelineExecutions).To(HaveLen(5))
+		})
+
+		Context("when the execution limit is smaller than the executions since the given ref", func() {
+			BeforeEach(func() {
+				gateServer.AppendHandlers(
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"id": "PC1", "name": "existent_pipeline"}}),
+				)
+
+				var err error
+				spinClient, err = spinnaker.NewClient(concourse.Source{
+					SpinnakerAPI:         gateServer.URL(),
+					SpinnakerApplication: "existent_app",
+					SpinnakerPipeline:    "existent_pipeline",
+					ExecutionLimit:       2,
+					X509Cert:             serverCert,
+					X509Key:              serverKey,
+				})
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("widens the window until the ref is found", func() {
+				gateServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=2"),
+						ghttp.RespondWithJSONEncoded(200, executions[:2]),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=4"),
+						ghttp.RespondWithJSONEncoded(200, executions[:4]),
+					),
+				)
+
+				pipelineExecutions, err := spinClient.GetPipelineExecutions("EX2")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pipelineExecutions).To(HaveLen(4))
+			})
+
+			It("stops when there are no older executions", func() {
+				gateServer.AppendHandlers(
+					ghttp.RespondWithJSONEncoded(200, executions[:2]),
+					ghttp.RespondWithJSONEncoded(200, executions[:4]),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=PC1&limit=8"),
+						ghttp.RespondWithJSONEncoded(200, executions),
+					),
+				)
+
+				pipelineExecutions, err := spinClient.GetPipelineExecutions("DELETED")
+				Expect(err).ToNot(HaveOccurred())
+				Expect(pipelineExecutions).To(HaveLen(5))
+			})
+		})
+	})
+})
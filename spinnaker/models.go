@@ -9,14 +9,246 @@ Unless required by applicable law or agreed to in writing, software distributed
 */
 package spinnaker
 
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+)
+
+// PipelineConfig is a pipeline definition as returned by /applications/{application}/pipelineConfigs.
+type PipelineConfig struct {
+	ID                string             `json:"id"`
+	Name              string             `json:"name"`
+	Application       string             `json:"application"`
+	Type              string             `json:"type,omitempty"`
+	ParameterConfig   []ParameterConfig  `json:"parameterConfig,omitempty"`
+	ExpectedArtifacts []ExpectedArtifact `json:"expectedArtifacts,omitempty"`
+}
+
+// ParameterConfig declares a parameter the pipeline can be triggered with.
+type ParameterConfig struct {
+	Name        string            `json:"name"`
+	Label       string            `json:"label,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Required    bool              `json:"required"`
+	Default     ParameterValue    `json:"default,omitempty"`
+	HasOptions  bool              `json:"hasOptions"`
+	Options     []ParameterOption `json:"options,omitempty"`
+}
+
+type ParameterOption struct {
+	Value ParameterValue `json:"value"`
+}
+
+// ParameterValue is a parameter default or option value. Pipelines saved through the API can
+// declare them as numbers or booleans, which are kept as their JSON text, e.g. "3" or "true".
+type ParameterValue string
+
+func (v *ParameterValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = ParameterValue(s)
+		return nil
+	}
+	*v = ParameterValue(data)
+	return nil
+}
+
+// PipelineExecution is a single run of a pipeline as returned by /pipelines/{id}.
 type PipelineExecution struct {
+	ID                 string         `json:"id"`
+	Type               string         `json:"type"`
+	Name               string         `json:"name"`
+	Application        string         `json:"application"`
+	PipelineConfigID   string         `json:"pipelineConfigId"`
+	BuildTime          uint64         `json:"buildTime"`
+	StartTime          int64          `json:"startTime"`
+	EndTime            int64          `json:"endTime"`
+	Status             string         `json:"status"`
+	Canceled           bool           `json:"canceled"`
+	CanceledBy         string         `json:"canceledBy,omitempty"`
+	CancellationReason string         `json:"cancellationReason,omitempty"`
+	Origin             string         `json:"origin"`
+	Authentication     Authentication `json:"authentication"`
+	Trigger            Trigger        `json:"trigger"`
+	Stages             []Stage        `json:"stages"`
+}
+
+// Version is the resource version of the execution.
+func (p PipelineExecution) Version() concourse.Version {
+	version := concourse.Version{Ref: p.ID}
+	if p.StartTime > 0 {
+		version.StartTime = strconv.FormatInt(p.StartTime, 10)
+	}
+	return version
+}
+
+type Authentication struct {
+	User            string   `json:"user"`
+	AllowedAccounts []string `json:"allowedAccounts"`
+}
+
+// Trigger describes what started an execution and with which parameters and artifacts.
+type Trigger struct {
+	Type                      string                 `json:"type"`
+	User                      string                 `json:"user"`
+	Team                      string                 `json:"team,omitempty"`
+	Pipeline                  string                 `json:"pipeline,omitempty"`
+	Job                       string                 `json:"job,omitempty"`
+	BuildInfo                 *BuildInfo             `json:"buildInfo,omitempty"`
+	DryRun                    bool                   `json:"dryRun"`
+	Parameters                map[string]interface{} `json:"parameters"`
+	Artifacts                 []Artifact             `json:"artifacts"`
+	ResolvedExpectedArtifacts []ExpectedArtifact     `json:"resolvedExpectedArtifacts"`
+}
+
+// BuildInfo describes the CI build that triggered the execution.
+type BuildInfo struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// StageReport is the result of the Concourse build started by a concourse stage, merged into
+// the stage context under concourseReport.
+type StageReport struct {
+	BuildInfo            ReportedBuild          `json:"buildInfo"`
+	PropertyFileContents map[string]interface{} `json:"propertyFileContents,omitempty"`
+}
+
+type ReportedBuild struct {
+	Job      string `json:"job,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Number   *int   `json:"number,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Result   string `json:"result"`
+	Building bool   `json:"building"`
+}
+
+type Stage struct {
+	ID                   string   `json:"id"`
+	RefID                string   `json:"refId"`
+	Name                 string   `json:"name"`
+	Type                 string   `json:"type"`
+	Status               string   `json:"status"`
+	StartTime            int64    `json:"startTime"`
+	EndTime              int64    `json:"endTime"`
+	ParentStageID        string   `json:"parentStageId,omitempty"`
+	SyntheticStageOwner  string   `json:"syntheticStageOwner,omitempty"`
+	RequisiteStageRefIDs []string `json:"requisiteStageRefIds"`
+	Context              Context  `json:"context"`
+	Outputs              Outputs  `json:"outputs"`
+	Tasks                []Task   `json:"tasks"`
+}
+
+type Task struct {
 	ID        string `json:"id"`
 	Name      string `json:"name"`
-	BuildTime uint64 `json:"buildTime"`
 	Status    string `json:"status"`
-	Stages    []struct {
-		RefID  string `json:"refId"`
-		Status string `json:"status"`
-		Type   string `json:"type"`
+	StartTime int64  `json:"startTime"`
+	EndTime   int64  `json:"endTime"`
+}
+
+// Context is the free-form configuration and state of a stage. Its keys depend on the stage type.
+type Context map[string]interface{}
+
+// Outputs are the values a stage makes available to downstream stages. Its keys depend on the stage type.
+type Outputs map[string]interface{}
+
+// Exception is the failure recorded in a stage context under "exception".
+type Exception struct {
+	ExceptionType string `json:"exceptionType"`
+	Details       struct {
+		Error  string   `json:"error"`
+		Errors []string `json:"errors"`
+	} `json:"details"`
+}
+
+// Exception returns the failure recorded in the context, if any.
+func (c Context) Exception() (Exception, bool) {
+	var exception Exception
+	raw, ok := c["exception"]
+	if !ok || raw == nil {
+		return exception, false
+	}
+	if err := remarshal(raw, &exception); err != nil {
+		return exception, false
+	}
+	return exception, true
+}
+
+// Artifacts returns the artifacts the stage produced, including the artifacts bound to its
+// resolved expected artifacts.
+func (o Outputs) Artifacts() []Artifact {
+	var artifacts []Artifact
+	if raw, ok := o["artifacts"]; ok && raw != nil {
+		remarshal(raw, &artifacts)
+	}
+	var expected []ExpectedArtifact
+	if raw, ok := o["resolvedExpectedArtifacts"]; ok && raw != nil {
+		remarshal(raw, &expected)
+	}
+	for _, e := range expected {
+		if e.BoundArtifact != nil {
+			artifacts = append(artifacts, *e.BoundArtifact)
+		}
 	}
+	return artifacts
+}
+
+// Artifacts returns every distinct artifact of the execution: those it was triggered with
+// followed by those produced by its stages.
+func (p PipelineExecution) Artifacts() []Artifact {
+	artifacts := append([]Artifact{}, p.Trigger.Artifacts...)
+	for _, e := range p.Trigger.ResolvedExpectedArtifacts {
+		if e.BoundArtifact != nil {
+			artifacts = append(artifacts, *e.BoundArtifact)
+		}
+	}
+	for _, stage := range p.Stages {
+		artifacts = append(artifacts, stage.Outputs.Artifacts()...)
+	}
+
+	seen := map[string]bool{}
+	distinct := artifacts[:0]
+	for _, artifact := range artifacts {
+		key := strings.Join([]string{artifact.Type, artifact.Name, artifact.Version, artifact.Location, artifact.Reference}, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		distinct = append(distinct, artifact)
+	}
+	return distinct
+}
+
+// remarshal converts a generically decoded JSON value into a typed one.
+func remarshal(raw interface{}, v interface{}) error {
+	bytes, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+type Artifact struct {
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name,omitempty"`
+	Version         string                 `json:"version,omitempty"`
+	Location        string                 `json:"location,omitempty"`
+	Reference       string                 `json:"reference,omitempty"`
+	ArtifactAccount string                 `json:"artifactAccount,omitempty"`
+	Provenance      string                 `json:"provenance,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
+}
+
+type ExpectedArtifact struct {
+	ID                 string    `json:"id"`
+	DisplayName        string    `json:"displayName,omitempty"`
+	MatchArtifact      *Artifact `json:"matchArtifact,omitempty"`
+	DefaultArtifact    *Artifact `json:"defaultArtifact,omitempty"`
+	UseDefaultArtifact bool      `json:"useDefaultArtifact,omitempty"`
+	UsePriorArtifact   bool      `json:"usePriorArtifact,omitempty"`
+	BoundArtifact      *Artifact `json:"boundArtifact,omitempty"`
 }
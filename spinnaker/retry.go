@@ -0,0 +1,192 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+)
+
+const (
+	defaultRequestTimeout  = "1m"
+	defaultRetryBackoff    = "1s"
+	defaultRetryMaxBackoff = "30s"
+)
+
+// retryPolicy decides whether and when a failed Gate request is sent again. Idempotent
+// requests are retried on connection errors, 5xx and 429 responses. Other requests are
+// only retried when Gate cannot have acted on them: when the connection could not be
+// established, or when Gate rate limited them with a 429.
+type retryPolicy struct {
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	sleep      func(time.Duration)
+}
+
+func newRetryPolicy(source concourse.Source) (retryPolicy, error) {
+	if source.Retries < 0 {
+		return retryPolicy{}, fmt.Errorf("invalid retries: %d, must not be negative", source.Retries)
+	}
+	backoff, err := parseDuration("retry_backoff", source.RetryBackoff, defaultRetryBackoff)
+	if err != nil {
+		return retryPolicy{}, err
+	}
+	maxBackoff, err := parseDuration("retry_max_backoff", source.RetryMaxBackoff, defaultRetryMaxBackoff)
+	if err != nil {
+		return retryPolicy{}, err
+	}
+	return retryPolicy{
+		retries:    source.Retries,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+		sleep:      time.Sleep,
+	}, nil
+}
+
+func parseDuration(name, value, defaultValue string) (time.Duration, error) {
+	if value == "" {
+		value = defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, err)
+	}
+	return duration, nil
+}
+
+// delay returns how long to wait before the given retry attempt, starting at 0. It grows
+// exponentially up to maxBackoff, with jitter so concurrent checks don't retry in lockstep.
+// A Retry-After header on the failed response takes precedence, up to maxBackoff too.
+func (p retryPolicy) delay(attempt int, response *http.Response) time.Duration {
+	if response != nil {
+		if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+			if retryAfter > p.maxBackoff {
+				return p.maxBackoff
+			}
+			return retryAfter
+		}
+	}
+
+	if p.backoff <= 0 {
+		return 0
+	}
+	delay := p.backoff << uint(attempt)
+	if delay > p.maxBackoff || delay < p.backoff {
+		delay = p.maxBackoff
+	}
+	if delay <= 1 {
+		return delay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+func (p retryPolicy) shouldRetry(idempotent bool, response *http.Response, err error) bool {
+	if err != nil {
+		return idempotent || isDialError(err)
+	}
+	if response.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return idempotent && response.StatusCode >= 500
+}
+
+// isDialError reports whether err happened while connecting, before anything was sent.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (c *SpinClient) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req, true)
+}
+
+func (c *SpinClient) post(url string, body []byte, idempotent bool) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, idempotent)
+}
+
+func (c *SpinClient) patch(url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("PATCH", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, true)
+}
+
+func (c *SpinClient) put(url string) (*http.Response, error) {
+	req, err := http.NewRequest("PUT", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req, true)
+}
+
+// do sends req, retrying according to the client's retry policy.
+func (c *SpinClient) do(req *http.Request, idempotent bool) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		response, err := c.client.Do(req)
+		if attempt >= c.retry.retries || !c.retry.shouldRetry(idempotent, response, err) {
+			return response, err
+		}
+
+		delay := c.retry.delay(attempt, response)
+		if response != nil {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+		c.retry.sleep(delay)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("Retries", func() {
+	var (
+		gateServer                                                     *ghttp.Server
+		source                                                         concourse.Source
+		applicationHandler, pipelineConfigsHandler, unavailableHandler http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		applicationHandler = ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"})
+		pipelineConfigsHandler = ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}})
+		unavailableHandler = ghttp.RespondWith(502, "bad gateway")
+
+		gateServer = ghttp.NewServer()
+		source = concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: "existent_app",
+			SpinnakerPipeline:    "existent_pipeline",
+			X509Cert:             serverCert,
+			X509Key:              serverKey,
+			Retries:              2,
+			RetryBackoff:         "1ms",
+		}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	Context("when retries are not configured", func() {
+		BeforeEach(func() {
+			source.Retries = 0
+			gateServer.AppendHandlers(unavailableHandler)
+		})
+
+		It("fails on the first error", func() {
+			_, err := spinnaker.NewClient(source)
+			Expect(err).To(MatchError("spinnaker api responded with status code: 502, body: bad gateway"))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when a GET fails with a 5xx response", func() {
+		It("retries until it succeeds", func() {
+			gateServer.AppendHandlers(unavailableHandler, unavailableHandler, applicationHandler, pipelineConfigsHandler)
+
+			_, err := spinnaker.NewClient(source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+		})
+
+		It("gives up after the configured number of retries", func() {
+			gateServer.AppendHandlers(unavailableHandler, unavailableHandler, unavailableHandler)
+
+			_, err := spinnaker.NewClient(source)
+			Expect(err).To(MatchError("spinnaker api responded with status code: 502, body: bad gateway"))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+		})
+	})
+
+	Context("when a GET is rate limited", func() {
+		It("waits for the duration given in Retry-After", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(429, "slow down", http.Header{"Retry-After": []string{"1"}}),
+				applicationHandler,
+				pipelineConfigsHandler,
+			)
+
+			start := time.Now()
+			_, err := spinnaker.NewClient(source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", time.Second))
+		})
+
+		It("waits no longer than retry_max_backoff", func() {
+			source.RetryMaxBackoff = "10ms"
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(429, "slow down", http.Header{"Retry-After": []string{"3600"}}),
+				applicationHandler,
+				pipelineConfigsHandler,
+			)
+
+			start := time.Now()
+			_, err := spinnaker.NewClient(source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+	})
+
+	Context("when invoking a pipeline", func() {
+		var spinClient spinnaker.SpinClient
+
+		BeforeEach(func() {
+			gateServer.AppendHandlers(applicationHandler, pipelineConfigsHandler)
+
+			var err error
+			spinClient, err = spinnaker.NewClient(source)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not re-POST after a 5xx response", func() {
+			gateServer.AppendHandlers(unavailableHandler)
+
+			_, err := spinClient.InvokePipelineExecution([]byte("{}"))
+			Expect(err).To(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		It("re-POSTs the same body after a 429 response", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(429, "slow down", http.Header{"Retry-After": []string{"0"}}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"type":"concourse-resource"}`),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/EX1"}),
+				),
+			)
+
+			execution, err := spinClient.InvokePipelineExecution([]byte(`{"type":"concourse-resource"}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(execution.ID).To(Equal("EX1"))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+		})
+	})
+
+	Context("when Gate does not respond within the request timeout", func() {
+		BeforeEach(func() {
+			source.Retries = 0
+			source.RequestTimeout = "50ms"
+			gateServer.AppendHandlers(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			})
+		})
+
+		It("returns an error", func() {
+			_, err := spinnaker.NewClient(source)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("Client.Timeout exceeded"))
+		})
+	})
+
+	Context("when a duration is invalid", func() {
+		BeforeEach(func() {
+			source.RetryBackoff = "soon"
+		})
+
+		It("returns an error", func() {
+			_, err := spinnaker.NewClient(source)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("invalid retry_backoff: "))
+		})
+	})
+})
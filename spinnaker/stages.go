@@ -0,0 +1,84 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+)
+
+// StageSelector selects the stages of an execution by one of their fields.
+type StageSelector struct {
+	Field string
+	Value string
+}
+
+const (
+	StageRefID = "refId"
+	StageName  = "name"
+	StageType  = "type"
+)
+
+// ParseStageSelector parses refId:<refId>, name:<name> or type:<type>. Any other value is a
+// refId, as spinnaker_stage used to only select stages by refId.
+func ParseStageSelector(selector string) StageSelector {
+	if i := strings.Index(selector, ":"); i > 0 {
+		switch field := selector[:i]; field {
+		case StageRefID, StageName, StageType:
+			return StageSelector{Field: field, Value: selector[i+1:]}
+		}
+	}
+	return StageSelector{Field: StageRefID, Value: selector}
+}
+
+// StageSelectors returns the selectors of spinnaker_stage and spinnaker_stages.
+func StageSelectors(source concourse.Source) []StageSelector {
+	var selectors []StageSelector
+	if source.SpinnakerStage != "" {
+		selectors = append(selectors, ParseStageSelector(source.SpinnakerStage))
+	}
+	for _, selector := range source.SpinnakerStages {
+		selectors = append(selectors, ParseStageSelector(selector))
+	}
+	return selectors
+}
+
+func (s StageSelector) Matches(stage Stage) bool {
+	switch s.Field {
+	case StageName:
+		return stage.Name == s.Value
+	case StageType:
+		return stage.Type == s.Value
+	default:
+		return stage.RefID == s.Value
+	}
+}
+
+func (s StageSelector) String() string {
+	return fmt.Sprintf("%s:%s", s.Field, s.Value)
+}
+
+// MatchingStage returns the first stage of the execution matched by one of the selectors with
+// one of the statuses, or any status if there are none.
+func (p PipelineExecution) MatchingStage(selectors []StageSelector, statuses []string) (Stage, bool) {
+	for _, stage := range p.Stages {
+		if len(statuses) > 0 && !InStatuses(stage.Status, statuses) {
+			continue
+		}
+		for _, selector := range selectors {
+			if selector.Matches(stage) {
+				return stage, true
+			}
+		}
+	}
+	return Stage{}, false
+}
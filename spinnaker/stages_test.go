@@ -0,0 +1,58 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stage selectors", func() {
+	execution := spinnaker.PipelineExecution{
+		Stages: []spinnaker.Stage{
+			{RefID: "1", Name: "Bake", Type: "bake", Status: "SUCCEEDED"},
+			{RefID: "2", Name: "Approve", Type: "manualJudgment", Status: "RUNNING"},
+			{RefID: "3", Name: "Deploy to prod", Type: "deploy", Status: "NOT_STARTED"},
+		},
+	}
+
+	It("parses the field of the selector", func() {
+		Expect(spinnaker.ParseStageSelector("name:Deploy to prod")).To(Equal(spinnaker.StageSelector{Field: "name", Value: "Deploy to prod"}))
+		Expect(spinnaker.ParseStageSelector("type:manualJudgment")).To(Equal(spinnaker.StageSelector{Field: "type", Value: "manualJudgment"}))
+		Expect(spinnaker.ParseStageSelector("refId:2")).To(Equal(spinnaker.StageSelector{Field: "refId", Value: "2"}))
+	})
+
+	It("selects by refId when the selector has no field", func() {
+		Expect(spinnaker.ParseStageSelector("2")).To(Equal(spinnaker.StageSelector{Field: "refId", Value: "2"}))
+		Expect(spinnaker.ParseStageSelector("stage:2")).To(Equal(spinnaker.StageSelector{Field: "refId", Value: "stage:2"}))
+	})
+
+	It("combines spinnaker_stage and spinnaker_stages", func() {
+		selectors := spinnaker.StageSelectors(concourse.Source{SpinnakerStage: "1", SpinnakerStages: []string{"type:deploy"}})
+		Expect(selectors).To(Equal([]spinnaker.StageSelector{{Field: "refId", Value: "1"}, {Field: "type", Value: "deploy"}}))
+	})
+
+	It("returns the first stage matched by any selector in one of the statuses", func() {
+		selectors := []spinnaker.StageSelector{{Field: "type", Value: "deploy"}, {Field: "name", Value: "Approve"}}
+
+		stage, ok := execution.MatchingStage(selectors, nil)
+		Expect(ok).To(BeTrue())
+		Expect(stage.RefID).To(Equal("2"))
+
+		stage, ok = execution.MatchingStage(selectors, []string{"NOT_STARTED"})
+		Expect(ok).To(BeTrue())
+		Expect(stage.RefID).To(Equal("3"))
+
+		_, ok = execution.MatchingStage(selectors, []string{"SUCCEEDED"})
+		Expect(ok).To(BeFalse())
+	})
+})
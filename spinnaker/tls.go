@@ -0,0 +1,49 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+)
+
+// newTLSConfig builds the TLS configuration used to talk to Gate. Server certificates
+// are verified against the system roots, or against spinnaker_ca_cert when it is set,
+// unless skip_tls_verify is explicitly enabled. The x509 client certificate is required
+// when it is the authentication mode, and optional otherwise.
+func newTLSConfig(source concourse.Source, requireClientCert bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: true,
+		ServerName:               source.ServerName,
+		InsecureSkipVerify:       source.SkipTLSVerify,
+	}
+
+	if requireClientCert || source.X509Cert != "" || source.X509Key != "" {
+		cert, err := tls.X509KeyPair([]byte(source.X509Cert), []byte(source.X509Key))
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if source.CACert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(source.CACert)) {
+			return nil, fmt.Errorf("spinnaker_ca_cert does not contain any valid PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package trigger
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// buildArtifacts builds the artifacts declared in the artifacts param.
+func buildArtifacts(sourcesDir string, specs []concourse.ArtifactSpec) ([]spinnaker.Artifact, error) {
+	artifacts := make([]spinnaker.Artifact, 0, len(specs))
+	for i, spec := range specs {
+		artifact, err := buildArtifact(sourcesDir, spec)
+		if err != nil {
+			return nil, fmt.Errorf("artifacts[%d] (%s): %s", i, spec.Type, err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+func buildArtifact(sourcesDir string, spec concourse.ArtifactSpec) (spinnaker.Artifact, error) {
+	artifact := spinnaker.Artifact{
+		Type:            spec.Type,
+		Name:            spec.Name,
+		Version:         spec.Version,
+		Reference:       spec.Reference,
+		Location:        spec.Location,
+		ArtifactAccount: spec.ArtifactAccount,
+	}
+
+	switch spec.Type {
+	case "docker/image":
+		if spec.ImageDir != "" {
+			repository, err := readTrimmed(sourcesDir, spec.ImageDir, "repository")
+			if err != nil {
+				return artifact, err
+			}
+			digest, err := readTrimmed(sourcesDir, spec.ImageDir, "digest")
+			if err != nil {
+				return artifact, err
+			}
+			artifact.Name = repository
+			artifact.Version = digest
+			artifact.Reference = repository + "@" + digest
+		}
+		if artifact.Name == "" || artifact.Reference == "" {
+			return artifact, errors.New("requires image_dir, or name and reference")
+		}
+	case "embedded/base64":
+		if spec.File == "" {
+			return artifact, errors.New("requires file")
+		}
+		contents, err := ioutil.ReadFile(filepath.Join(sourcesDir, spec.File))
+		if err != nil {
+			return artifact, err
+		}
+		artifact.Reference = base64.StdEncoding.EncodeToString(contents)
+		if artifact.Name == "" {
+			artifact.Name = filepath.Base(spec.File)
+		}
+	case "github/file":
+		if artifact.Name == "" || artifact.Reference == "" {
+			return artifact, errors.New("requires name, the path of the file, and reference, its contents API url")
+		}
+	case "http/file":
+		if artifact.Reference == "" {
+			return artifact, errors.New("requires reference")
+		}
+		if !strings.HasPrefix(artifact.Reference, "http://") && !strings.HasPrefix(artifact.Reference, "https://") {
+			return artifact, fmt.Errorf("reference %q must be an http or https url", artifact.Reference)
+		}
+		if artifact.Name == "" {
+			artifact.Name = artifact.Reference
+		}
+	case "s3/object":
+		if artifact.Reference == "" {
+			return artifact, errors.New("requires reference")
+		}
+		if !strings.HasPrefix(artifact.Reference, "s3://") {
+			return artifact, fmt.Errorf("reference %q must be an s3:// url", artifact.Reference)
+		}
+		if artifact.Name == "" {
+			artifact.Name = artifact.Reference
+		}
+	case "helm/chart":
+		if artifact.Name == "" || artifact.Version == "" || artifact.ArtifactAccount == "" {
+			return artifact, errors.New("requires name, version and artifact_account")
+		}
+	default:
+		return artifact, fmt.Errorf("unsupported artifact type %q, must be one of: docker/image, embedded/base64, github/file, http/file, s3/object, helm/chart", spec.Type)
+	}
+
+	return artifact, nil
+}
+
+func readTrimmed(sourcesDir string, path ...string) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(append([]string{sourcesDir}, path...)...))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
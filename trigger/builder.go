@@ -0,0 +1,119 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+
+// Package trigger builds the payload a Spinnaker pipeline is triggered with from the put params.
+package trigger
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// Type is the trigger type of the pipeline executions triggered by the resource.
+const Type = "concourse-resource"
+
+// Payload is the body POSTed to Gate to trigger a pipeline.
+type Payload struct {
+	Type        string                 `json:"type"`
+	User        string                 `json:"user,omitempty"`
+	Team        string                 `json:"team,omitempty"`
+	Pipeline    string                 `json:"pipeline,omitempty"`
+	Job         string                 `json:"job,omitempty"`
+	BuildNumber *int                   `json:"buildNumber,omitempty"`
+	BuildInfo   *spinnaker.BuildInfo   `json:"buildInfo,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Artifacts   []interface{}          `json:"artifacts,omitempty"`
+}
+
+// Builder builds the Payload. It only reads files from SourcesDir and variables from Env, so
+// the same inputs always build the same payload.
+type Builder struct {
+	Params     concourse.OutParams
+	SourcesDir string
+	Env        map[string]string
+}
+
+// NewBuilder returns a Builder for the params of a put step, reading the environment of the
+// process.
+func NewBuilder(params concourse.OutParams, sourcesDir string) Builder {
+	return Builder{Params: params, SourcesDir: sourcesDir, Env: Environ()}
+}
+
+// Environ returns the environment of the process as a map.
+func Environ() map[string]string {
+	env := map[string]string{}
+	for _, variable := range os.Environ() {
+		if i := strings.Index(variable, "="); i > 0 {
+			env[variable[:i]] = variable[i+1:]
+		}
+	}
+	return env
+}
+
+// Build builds the payload: the build metadata, trigger_params merged with
+// trigger_params_json_file, and the artifacts of artifacts_json_file followed by artifacts.
+func (b Builder) Build() (Payload, error) {
+	payload := b.Metadata()
+
+	renderer := newTemplateRenderer(b.SourcesDir, b.Params.StrictTemplates, b.Env)
+
+	parameters := map[string]interface{}{}
+	for key, value := range b.Params.TriggerParams {
+		rendered, err := renderer.renderParam("trigger_params."+key, value)
+		if err != nil {
+			return Payload{}, err
+		}
+		parameters[key] = rendered
+	}
+	if len(b.Params.TriggerParamsJSONFilePath) > 0 {
+		fileParameters, err := ReadParamsFile(filepath.Join(b.SourcesDir, b.Params.TriggerParamsJSONFilePath))
+		if err != nil {
+			return Payload{}, err
+		}
+		for key, value := range fileParameters {
+			parameters[key] = value
+		}
+	}
+	if len(parameters) > 0 {
+		payload.Parameters = parameters
+	}
+
+	if len(b.Params.Artifacts) > 0 {
+		contents, err := ioutil.ReadFile(filepath.Join(b.SourcesDir, b.Params.Artifacts))
+		if err != nil {
+			return Payload{}, err
+		}
+		rendered, err := renderer.render("artifacts_json_file", string(contents))
+		if err != nil {
+			return Payload{}, err
+		}
+		if err = json.Unmarshal([]byte(rendered), &payload.Artifacts); err != nil {
+			return Payload{}, errors.New("artifacts_json_file must contain an array of artifacts: " + err.Error())
+		}
+	}
+	if len(b.Params.ArtifactSpecs) > 0 {
+		artifacts, err := buildArtifacts(b.SourcesDir, b.Params.ArtifactSpecs)
+		if err != nil {
+			return Payload{}, err
+		}
+		for _, artifact := range artifacts {
+			payload.Artifacts = append(payload.Artifacts, artifact)
+		}
+	}
+
+	return payload, nil
+}
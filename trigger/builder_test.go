@@ -0,0 +1,199 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package trigger_test
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/hellofresh/spinnaker-resource/concourse"
+	"github.com/hellofresh/spinnaker-resource/trigger"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata/golden")
+
+var buildEnv = map[string]string{
+	"ATC_EXTERNAL_URL":    "https://ci.example.com",
+	"BUILD_TEAM_NAME":     "main",
+	"BUILD_PIPELINE_NAME": "app",
+	"BUILD_JOB_NAME":      "deploy",
+	"BUILD_NAME":          "42",
+	"REGION":              "eu-west-1",
+}
+
+type paramsOption struct {
+	name  string
+	apply func(*concourse.OutParams)
+}
+
+var inlineParamsOptions = []paramsOption{
+	{"no-inline-params", func(*concourse.OutParams) {}},
+	{"inline-params", func(params *concourse.OutParams) {
+		params.TriggerParams = map[string]interface{}{
+			"version": "from-inline",
+			"region":  "$REGION",
+			"build":   "{{ .BUILD_JOB_NAME }}/{{ .BUILD_NAME }}",
+			"debug":   false,
+		}
+	}},
+}
+
+var paramsFileOptions = []paramsOption{
+	{"no-params-file", func(*concourse.OutParams) {}},
+	{"json-params-file", func(params *concourse.OutParams) { params.TriggerParamsJSONFilePath = "params.json" }},
+	{"yaml-params-file", func(params *concourse.OutParams) { params.TriggerParamsJSONFilePath = "params.yml" }},
+}
+
+var artifactsOptions = []paramsOption{
+	{"no-artifacts", func(*concourse.OutParams) {}},
+	{"artifacts-file", func(params *concourse.OutParams) { params.Artifacts = "artifacts.json" }},
+	{"artifact-specs", func(params *concourse.OutParams) {
+		params.ArtifactSpecs = []concourse.ArtifactSpec{
+			{Type: "docker/image", ImageDir: "image"},
+			{Type: "embedded/base64", File: "manifest.yml"},
+		}
+	}},
+	{"artifacts-file-and-specs", func(params *concourse.OutParams) {
+		params.Artifacts = "artifacts.json"
+		params.ArtifactSpecs = []concourse.ArtifactSpec{{Type: "helm/chart", Name: "app", Version: "1.0.0", ArtifactAccount: "charts"}}
+	}},
+}
+
+func expectGolden(name string, payload trigger.Payload) {
+	actual, err := json.MarshalIndent(payload, "", "  ")
+	Expect(err).ToNot(HaveOccurred())
+	actual = append(actual, '\n')
+
+	path := filepath.Join("testdata", "golden", name+".json")
+	if *update {
+		Expect(ioutil.WriteFile(path, actual, 0644)).To(Succeed())
+	}
+	expected, err := ioutil.ReadFile(path)
+	Expect(err).ToNot(HaveOccurred(), "run go test ./trigger -update to create the golden file")
+	Expect(string(actual)).To(Equal(string(expected)))
+}
+
+var _ = Describe("Builder", func() {
+	for _, inline := range inlineParamsOptions {
+		for _, file := range paramsFileOptions {
+			for _, artifacts := range artifactsOptions {
+				inline, file, artifacts := inline, file, artifacts
+				name := strings.Join([]string{inline.name, file.name, artifacts.name}, "_")
+
+				It("builds the payload with "+name, func() {
+					params := concourse.OutParams{}
+					inline.apply(&params)
+					file.apply(&params)
+					artifacts.apply(&params)
+
+					payload, err := trigger.Builder{Params: params, SourcesDir: "testdata/sources", Env: buildEnv}.Build()
+					Expect(err).ToNot(HaveOccurred())
+					expectGolden(name, payload)
+				})
+			}
+		}
+	}
+
+	It("builds the payload without build metadata", func() {
+		payload, err := trigger.Builder{SourcesDir: "testdata/sources", Env: map[string]string{}}.Build()
+		Expect(err).ToNot(HaveOccurred())
+		expectGolden("no-build-metadata", payload)
+	})
+
+	It("builds the payload of a rerun without a build number", func() {
+		env := map[string]string{}
+		for name, value := range buildEnv {
+			env[name] = value
+		}
+		env["BUILD_NAME"] = "42.1"
+
+		payload, err := trigger.Builder{SourcesDir: "testdata/sources", Env: env}.Build()
+		Expect(err).ToNot(HaveOccurred())
+		expectGolden("rerun", payload)
+	})
+
+	It("does not carry params or artifacts over to the next payload", func() {
+		_, err := trigger.Builder{
+			Params:     concourse.OutParams{TriggerParams: map[string]interface{}{"version": "1.0"}, Artifacts: "artifacts.json"},
+			SourcesDir: "testdata/sources",
+			Env:        buildEnv,
+		}.Build()
+		Expect(err).ToNot(HaveOccurred())
+
+		payload, err := trigger.Builder{SourcesDir: "testdata/sources", Env: map[string]string{}}.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(payload).To(Equal(trigger.Payload{Type: trigger.Type}))
+	})
+
+	It("does not read the environment of the process", func() {
+		payload, err := trigger.Builder{
+			Params: concourse.OutParams{TriggerParams: map[string]interface{}{"home": "$HOME", "path": `{{ env "PATH" }}`}},
+			Env:    map[string]string{},
+		}.Build()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(payload.Parameters).To(Equal(map[string]interface{}{"home": "", "path": ""}))
+	})
+
+	It("keeps numbers of the params file as written", func() {
+		payload, err := trigger.Builder{
+			Params:     concourse.OutParams{TriggerParamsJSONFilePath: "ids.json"},
+			SourcesDir: "testdata/sources",
+			Env:        map[string]string{},
+		}.Build()
+		Expect(err).ToNot(HaveOccurred())
+		parameters, err := json.Marshal(payload.Parameters)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(parameters)).To(Equal(`{"commit_id":1234567890123456789,"ratio":0.5}`))
+	})
+
+	It("returns an error when the params file can't be parsed", func() {
+		_, err := trigger.Builder{
+			Params:     concourse.OutParams{TriggerParamsJSONFilePath: "artifacts.json"},
+			SourcesDir: "testdata/sources",
+		}.Build()
+		Expect(err).To(MatchError(HavePrefix("parsing testdata/sources/artifacts.json: ")))
+	})
+
+	It("returns an error when the artifacts file is not an array", func() {
+		_, err := trigger.Builder{
+			Params:     concourse.OutParams{Artifacts: "params.json"},
+			SourcesDir: "testdata/sources",
+		}.Build()
+		Expect(err).To(MatchError(HavePrefix("artifacts_json_file must contain an array of artifacts: ")))
+	})
+
+	It("returns an error when a strict template uses a missing variable", func() {
+		_, err := trigger.Builder{
+			Params: concourse.OutParams{
+				TriggerParams:   map[string]interface{}{"build": "{{ .BUILD_NAME }}"},
+				StrictTemplates: true,
+			},
+			Env: map[string]string{},
+		}.Build()
+		Expect(err).To(MatchError(ContainSubstring(`map has no entry for key "BUILD_NAME"`)))
+	})
+
+	It("returns an error when a strict param uses a missing environment variable", func() {
+		_, err := trigger.Builder{
+			Params: concourse.OutParams{
+				TriggerParams:   map[string]interface{}{"region": "$REGION"},
+				StrictTemplates: true,
+			},
+			Env: map[string]string{},
+		}.Build()
+		Expect(err).To(MatchError(ContainSubstring("environment variable REGION is not set")))
+	})
+})
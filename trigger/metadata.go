@@ -0,0 +1,65 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package trigger
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/hellofresh/spinnaker-resource/spinnaker"
+)
+
+// Metadata identifies the Concourse build triggering the pipeline, from the build metadata
+// Concourse sets in the environment. Fields without metadata are left out.
+func (b Builder) Metadata() Payload {
+	team := b.Env["BUILD_TEAM_NAME"]
+	pipeline := b.Env["BUILD_PIPELINE_NAME"]
+	job := b.Env["BUILD_JOB_NAME"]
+	buildName := b.Env["BUILD_NAME"]
+
+	payload := Payload{
+		Type:     Type,
+		User:     b.Env["BUILD_CREATED_BY"],
+		Team:     team,
+		Pipeline: pipeline,
+		Job:      job,
+	}
+	// Reruns are named like 42.1 and have no build number.
+	if number, err := strconv.Atoi(buildName); err == nil {
+		payload.BuildNumber = &number
+	}
+
+	buildInfo := spinnaker.BuildInfo{Name: buildName}
+	if externalURL := b.Env["ATC_EXTERNAL_URL"]; externalURL != "" && team != "" && pipeline != "" && job != "" && buildName != "" {
+		buildInfo.URL = buildURL(externalURL, team, pipeline, b.Env["BUILD_PIPELINE_INSTANCE_VARS"], job, buildName)
+	}
+	if buildInfo != (spinnaker.BuildInfo{}) {
+		payload.BuildInfo = &buildInfo
+	}
+
+	return payload
+}
+
+// buildURL is the url of the build in the Concourse UI.
+func buildURL(externalURL, team, pipeline, instanceVars, job, buildName string) string {
+	u := fmt.Sprintf("%s/teams/%s/pipelines/%s/jobs/%s/builds/%s",
+		strings.TrimSuffix(externalURL, "/"),
+		url.PathEscape(team),
+		url.PathEscape(pipeline),
+		url.PathEscape(job),
+		url.PathEscape(buildName),
+	)
+	if instanceVars != "" {
+		u += "?" + url.Values{"vars": {instanceVars}}.Encode()
+	}
+	return u
+}
@@ -0,0 +1,94 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package trigger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ReadParamsFile reads a map of params from a JSON file or, if its extension is .yml or .yaml,
+// a YAML file. Values keep their type.
+func ReadParamsFile(path string) (map[string]interface{}, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var params map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		var raw map[string]interface{}
+		if err = yaml.Unmarshal(contents, &raw); err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", path, err)
+		}
+		converted, err := fromYAML(raw)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", path, err)
+		}
+		params, _ = converted.(map[string]interface{})
+	default:
+		// Numbers are kept as written, so ids beyond the precision of a float64 are not changed.
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.UseNumber()
+		if err = decoder.Decode(&params); err != nil {
+			return nil, fmt.Errorf("parsing %s: %s", path, err)
+		}
+	}
+	return params, nil
+}
+
+// fromYAML converts the maps decoded by yaml, which may have keys of any type, into maps with
+// string keys so the value can be encoded as JSON.
+func fromYAML(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			c, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[key] = c
+		}
+		return converted, nil
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			k, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("key %v is not a string", key)
+			}
+			c, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[k] = c
+		}
+		return converted, nil
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, item := range v {
+			c, err := fromYAML(item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = c
+		}
+		return converted, nil
+	}
+	return value, nil
+}
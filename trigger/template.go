@@ -0,0 +1,147 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package trigger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// buildMetadataVars are the Concourse build metadata variables available as template data,
+// e.g. {{ .BUILD_NAME }}.
+var buildMetadataVars = []string{
+	"ATC_EXTERNAL_URL",
+	"BUILD_ID",
+	"BUILD_NAME",
+	"BUILD_JOB_NAME",
+	"BUILD_PIPELINE_NAME",
+	"BUILD_PIPELINE_INSTANCE_VARS",
+	"BUILD_TEAM_NAME",
+	"BUILD_CREATED_BY",
+}
+
+// templateRenderer renders trigger params and artifacts as Go templates. In strict mode,
+// missing build metadata and environment variables are errors instead of empty strings.
+type templateRenderer struct {
+	sourcesDir string
+	strict     bool
+	vars       map[string]string
+	data       map[string]string
+}
+
+func newTemplateRenderer(sourcesDir string, strict bool, env map[string]string) templateRenderer {
+	data := map[string]string{}
+	for _, name := range buildMetadataVars {
+		if value, ok := env[name]; ok {
+			data[name] = value
+		}
+	}
+	return templateRenderer{sourcesDir: sourcesDir, strict: strict, vars: env, data: data}
+}
+
+func (r templateRenderer) render(name, text string) (string, error) {
+	missingKey := "missingkey=zero"
+	if r.strict {
+		missingKey = "missingkey=error"
+	}
+
+	tmpl, err := template.New(name).Option(missingKey).Funcs(template.FuncMap{
+		"file": r.file,
+		"env":  r.env,
+		"json": toJSON,
+		"trim": strings.TrimSpace,
+	}).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %s: %s", name, err)
+	}
+
+	var rendered strings.Builder
+	if err = tmpl.Execute(&rendered, r.data); err != nil {
+		return "", fmt.Errorf("rendering template %s: %s", name, err)
+	}
+	return rendered.String(), nil
+}
+
+// renderParam renders every string of a trigger param that contains a template action. Other
+// strings only get environment variables expanded, as before templates were supported.
+func (r templateRenderer) renderParam(name string, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.Contains(v, "{{") {
+			return r.expand(name, v)
+		}
+		return r.render(name, v)
+	case map[string]interface{}:
+		rendered := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			renderedItem, err := r.renderParam(name+"."+key, item)
+			if err != nil {
+				return nil, err
+			}
+			rendered[key] = renderedItem
+		}
+		return rendered, nil
+	case []interface{}:
+		rendered := make([]interface{}, len(v))
+		for i, item := range v {
+			renderedItem, err := r.renderParam(fmt.Sprintf("%s.%d", name, i), item)
+			if err != nil {
+				return nil, err
+			}
+			rendered[i] = renderedItem
+		}
+		return rendered, nil
+	}
+	return value, nil
+}
+
+// expand expands the environment variables of a string without template actions. In strict
+// mode, a variable that is not set is an error, like in a template.
+func (r templateRenderer) expand(name, text string) (string, error) {
+	var err error
+	expanded := os.Expand(text, func(variable string) string {
+		value, envErr := r.env(variable)
+		if envErr != nil && err == nil {
+			err = fmt.Errorf("expanding %s: %s", name, envErr)
+		}
+		return value
+	})
+	return expanded, err
+}
+
+// file returns the contents of a file in the sources directory.
+func (r templateRenderer) file(path string) (string, error) {
+	contents, err := ioutil.ReadFile(filepath.Join(r.sourcesDir, path))
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
+
+func (r templateRenderer) env(name string) (string, error) {
+	value, ok := r.vars[name]
+	if !ok && r.strict {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+func toJSON(value interface{}) (string, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}